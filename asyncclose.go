@@ -0,0 +1,102 @@
+package doppelgangerreader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// NewAsyncCloseDoppelganger creates a new reader that acts like the original reader,
+// Close returns immediately and removes the reader from the factory in the background.
+// Use WaitForAsyncCloses to wait for the pending removals.
+func (factory *doppelgangerFactory) NewAsyncCloseDoppelganger() io.ReadCloser {
+	return newAsyncCloseDoppelganger(factory, &factory.asyncCloses)
+}
+
+// WaitForAsyncCloses blocks until all pending closes of async close doppelgangers finished,
+// or ctx is canceled.
+func (factory *doppelgangerFactory) WaitForAsyncCloses(ctx context.Context) error {
+	return waitForAsyncCloses(ctx, &factory.asyncCloses)
+}
+
+func newAsyncCloseDoppelganger(factory DoppelgangerFactory, pending *pendingCloses) io.ReadCloser {
+	return &asyncCloseReader{
+		ReadCloser: factory.NewDoppelganger(),
+		pending:    pending,
+	}
+}
+
+func waitForAsyncCloses(ctx context.Context, pending *pendingCloses) error {
+	select {
+	case <-pending.idle():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pendingCloses counts the pending closes of async close doppelgangers,
+// unlike a sync.WaitGroup closes can be added while someone waits.
+type pendingCloses struct {
+	mu sync.Mutex
+	n  int
+	// idleCh is closed once n drops to 0, see idle
+	idleCh chan struct{}
+}
+
+func (p *pendingCloses) add() {
+	p.mu.Lock()
+	p.n++
+	p.mu.Unlock()
+}
+
+func (p *pendingCloses) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n--
+	if p.n == 0 && p.idleCh != nil {
+		close(p.idleCh)
+		p.idleCh = nil
+	}
+}
+
+// idle returns a channel that will be closed once there are no pending closes.
+func (p *pendingCloses) idle() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if p.idleCh == nil {
+		p.idleCh = make(chan struct{})
+	}
+	return p.idleCh
+}
+
+type asyncCloseReader struct {
+	io.ReadCloser
+	pending *pendingCloses
+	closed  int32
+}
+
+func (r *asyncCloseReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return 0, ErrDoppelgangerClosed
+	}
+	return r.ReadCloser.Read(p)
+}
+
+func (r *asyncCloseReader) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		return nil
+	}
+	r.pending.add()
+	go func() {
+		defer r.pending.done()
+		_ = r.ReadCloser.Close()
+	}()
+	return nil
+}
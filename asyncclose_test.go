@@ -0,0 +1,61 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewAsyncCloseDoppelganger(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader := factory.NewAsyncCloseDoppelganger()
+	readAtLeast(t, reader, 5)
+
+	if err := reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := reader.Read(make([]byte, 1)); err != doppelgangerreader.ErrDoppelgangerClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDoppelgangerClosed, err)
+	}
+
+	if err := factory.WaitForAsyncCloses(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+}
+
+func TestAsyncCloseWhileWaiting(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		reader := factory.NewAsyncCloseDoppelganger()
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = reader.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			if err := factory.WaitForAsyncCloses(context.Background()); err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := factory.WaitForAsyncCloses(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+}
@@ -0,0 +1,67 @@
+package doppelgangerreader
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBarrierAborted will be reported by Barrier.Wait if a doppelganger has been closed before it reached the barrier
+var ErrBarrierAborted = errors.New("barrier aborted")
+
+// Barrier is a synchronization point at an offset of the stream, see NewBarrier
+type Barrier struct {
+	factory *doppelgangerFactory
+	offset  int64
+	readers []*readerInstance
+}
+
+// NewBarrier creates a synchronization point at offset for the doppelgangers that are active right now,
+// use Barrier.Wait to wait until all of them read at least offset bytes.
+func (factory *doppelgangerFactory) NewBarrier(offset int64) *Barrier {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return &Barrier{
+		factory: factory,
+		offset:  offset,
+		readers: append([]*readerInstance(nil), factory.readers...),
+	}
+}
+
+// Wait blocks until all doppelgangers of the barrier reached the offset, it does not read from the source.
+// Every doppelganger that waits must have read up to the offset before, otherwise Wait blocks forever.
+// It returns ErrBarrierAborted if a doppelganger has been closed before it reached the offset
+// and ctx.Err() if ctx is done before.
+func (b *Barrier) Wait(ctx context.Context) error {
+	factory := b.factory
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	factory.waiters++
+	defer func() {
+		factory.waiters--
+	}()
+	for {
+		arrived, err := b.arrived()
+		if err != nil || arrived {
+			return err
+		}
+		if !factory.wait(ctx.Done()) {
+			return ctx.Err()
+		}
+	}
+}
+
+// arrived returns true if all doppelgangers reached the offset.
+// factory.mu must be held.
+func (b *Barrier) arrived() (bool, error) {
+	arrived := true
+	for _, reader := range b.readers {
+		if reader.pos >= b.offset {
+			continue
+		}
+		if reader.closed {
+			return false, ErrBarrierAborted
+		}
+		arrived = false
+	}
+	return arrived, nil
+}
@@ -0,0 +1,79 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestBarrier(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	defer reader1.Close()
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+
+	barrier := factory.NewBarrier(5)
+
+	read(t, reader1, 5)
+	done := make(chan error, 1)
+	go func() {
+		done <- barrier.Wait(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected Wait to block, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	read(t, reader2, 6)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// the barrier stays open
+	if err := barrier.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBarrierAborted(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	defer reader1.Close()
+	reader2 := factory.NewDoppelganger()
+
+	barrier := factory.NewBarrier(5)
+	read(t, reader1, 5)
+	done := make(chan error, 1)
+	go func() {
+		done <- barrier.Wait(context.Background())
+	}()
+
+	_ = reader2.Close()
+	if err := <-done; err != doppelgangerreader.ErrBarrierAborted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBarrierAborted, err)
+	}
+}
+
+func TestBarrierContext(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+
+	barrier := factory.NewBarrier(5)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := barrier.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,76 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+)
+
+// NewBase58CheckDoppelganger creates a new reader that acts like the original reader
+// but delivers the stream in the Base58Check format (as used by bitcoin addresses):
+// the version byte, the stream and a double sha256 checksum, base58 encoded.
+// Because the checksum covers the whole stream the first Read consumes the source until the end.
+func NewBase58CheckDoppelganger(factory DoppelgangerFactory, version byte) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &base58CheckReader{
+		source:  reader,
+		Closer:  reader,
+		version: version,
+	}
+}
+
+type base58CheckReader struct {
+	io.Closer
+	source  io.Reader
+	version byte
+	// encoded is set after the source was consumed
+	encoded *bytes.Reader
+}
+
+func (r *base58CheckReader) Read(p []byte) (int, error) {
+	if r.encoded == nil {
+		data, err := ioutil.ReadAll(r.source)
+		if err != nil {
+			return 0, err
+		}
+		data = append([]byte{r.version}, data...)
+		first := sha256.Sum256(data)
+		second := sha256.Sum256(first[:])
+		r.encoded = bytes.NewReader(base58Encode(append(data, second[:4]...)))
+	}
+	return r.encoded.Read(p)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Encode(data []byte) []byte {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256) / log(58) ~ 1.37
+	digits := make([]byte, 0, len(data)*138/100+1)
+	for _, b := range data[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	result := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		result[i] = base58Alphabet[0]
+	}
+	for i, digit := range digits {
+		result[len(result)-1-i] = base58Alphabet[digit]
+	}
+	return result
+}
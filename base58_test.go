@@ -0,0 +1,37 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewBase58CheckDoppelganger(t *testing.T) {
+	// hash160 of the bitcoin genesis block public key
+	payload, err := hex.DecodeString("62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	buf, err := ioutil.ReadAll(doppelgangerreader.NewBase58CheckDoppelganger(factory, 0))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := []byte("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa")
+	if !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %s, but got %s", expected, buf)
+	}
+
+	buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
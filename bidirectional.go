@@ -0,0 +1,34 @@
+package doppelgangerreader
+
+import "io"
+
+// BidirectionalFactory is a DoppelgangerFactory for a source that can also be written to.
+// Reading is fanned out to the doppelgangers, writing is passed directly to the source.
+type BidirectionalFactory interface {
+	DoppelgangerFactory
+	// NewReadDoppelganger creates a new reader that acts like the read side of the source
+	NewReadDoppelganger() io.ReadCloser
+	// Write writes directly to the source, the data is neither buffered nor seen by the doppelgangers
+	Write(p []byte) (int, error)
+}
+
+// NewBidirectionalFactory creates a new BidirectionalFactory for the specified source
+func NewBidirectionalFactory(rw io.ReadWriter) BidirectionalFactory {
+	return &bidirectionalFactory{
+		DoppelgangerFactory: NewFactory(rw),
+		writer:              rw,
+	}
+}
+
+type bidirectionalFactory struct {
+	DoppelgangerFactory
+	writer io.Writer
+}
+
+func (factory *bidirectionalFactory) NewReadDoppelganger() io.ReadCloser {
+	return factory.NewDoppelganger()
+}
+
+func (factory *bidirectionalFactory) Write(p []byte) (int, error) {
+	return factory.writer.Write(p)
+}
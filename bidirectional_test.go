@@ -0,0 +1,44 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+type readWriter struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (rw *readWriter) Read(p []byte) (int, error) {
+	return rw.Reader.Read(p)
+}
+
+func TestNewBidirectionalFactory(t *testing.T) {
+	payload := []byte("Hello World")
+	rw := &readWriter{Reader: bytes.NewReader(payload)}
+
+	factory := doppelgangerreader.NewBidirectionalFactory(rw)
+	defer factory.Close()
+
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(factory.NewReadDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+
+	if _, err := factory.Write([]byte("Hi")); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if rw.String() != "Hi" {
+		t.Fatalf("expected %q, but got %q", "Hi", rw.String())
+	}
+}
@@ -0,0 +1,16 @@
+package doppelgangerreader
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// NewBrotliDoppelganger creates a new reader that decompresses the original brotli stream
+// (e.g. a response with Content-Encoding: br), other doppelgangers still see the compressed data.
+// It is a shortcut for NewDecodingDoppelganger with a brotli decoder.
+func NewBrotliDoppelganger(factory DoppelgangerFactory) (io.ReadCloser, error) {
+	return NewDecodingDoppelganger(factory, func(r io.Reader) (io.Reader, error) {
+		return brotli.NewReader(r), nil
+	})
+}
@@ -0,0 +1,44 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewBrotliDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	var compressed bytes.Buffer
+	w := brotli.NewWriter(&compressed)
+	_, _ = w.Write(payload)
+	_ = w.Close()
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(compressed.Bytes()))
+	defer factory.Close()
+
+	reader, err := doppelgangerreader.NewBrotliDoppelganger(factory)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	// other doppelgangers see the compressed data
+	buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(compressed.Bytes(), buf) {
+		t.Fatalf("expected %v, but got %v", compressed.Bytes(), buf)
+	}
+}
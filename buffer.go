@@ -0,0 +1,120 @@
+package doppelgangerreader
+
+import (
+	"sync"
+)
+
+// DefaultChunkSize is the size of the chunks the buffer uses to store the data of the source
+// if WithChunkSize is not used
+const DefaultChunkSize = 16 * 1024
+
+// chunkPools holds a *sync.Pool for every chunk size in use
+var chunkPools sync.Map
+
+func chunkPool(size int) *sync.Pool {
+	if pool, ok := chunkPools.Load(size); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := chunkPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} {
+			chunk := make([]byte, 0, size)
+			return &chunk
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
+// chunkedBuffer stores the data of the source in fixed size chunks that are taken from a sync.Pool.
+// Written data is never modified, so slices of it can be used without holding a lock
+// as long as the chunk is not evicted.
+type chunkedBuffer struct {
+	chunkSize int
+	chunks    [][]byte
+	// base is the position of the first byte in the first chunk, bytes before base have been evicted
+	base int64
+	// length is the number of bytes that are stored in the chunks
+	length int64
+}
+
+// size returns the number of bytes that have been written (including the evicted ones)
+func (b *chunkedBuffer) size() int64 {
+	return b.base + b.length
+}
+
+// write appends p to the buffer
+func (b *chunkedBuffer) write(p []byte) {
+	if b.chunkSize <= 0 {
+		b.chunkSize = DefaultChunkSize
+	}
+	for len(p) > 0 {
+		last := len(b.chunks) - 1
+		if last < 0 || len(b.chunks[last]) == b.chunkSize {
+			b.chunks = append(b.chunks, (*chunkPool(b.chunkSize).Get().(*[]byte))[:0])
+			last++
+		}
+		chunk := b.chunks[last]
+		n := copy(chunk[len(chunk):b.chunkSize], p)
+		b.chunks[last] = chunk[:len(chunk)+n]
+		b.length += int64(n)
+		p = p[n:]
+	}
+}
+
+// locate returns the chunk index and the offset inside the chunk for pos.
+// pos must be between base and size.
+func (b *chunkedBuffer) locate(pos int64) (int, int) {
+	rel := pos - b.base
+	return int(rel / int64(b.chunkSize)), int(rel % int64(b.chunkSize))
+}
+
+// slice returns the data starting at pos up to the end of the chunk pos is located in
+func (b *chunkedBuffer) slice(pos int64) []byte {
+	if pos >= b.size() {
+		return nil
+	}
+	i, off := b.locate(pos)
+	return b.chunks[i][off:]
+}
+
+// copyAt copies the data starting at pos into p and returns the number of bytes copied
+func (b *chunkedBuffer) copyAt(p []byte, pos int64) int {
+	n := 0
+	for n < len(p) {
+		chunk := b.slice(pos + int64(n))
+		if len(chunk) == 0 {
+			break
+		}
+		n += copy(p[n:], chunk)
+	}
+	return n
+}
+
+// bytes returns a copy of all stored data
+func (b *chunkedBuffer) bytes() []byte {
+	p := make([]byte, b.length)
+	b.copyAt(p, b.base)
+	return p
+}
+
+// evict releases all chunks that only hold data before pos
+func (b *chunkedBuffer) evict(pos int64) {
+	if b.chunkSize <= 0 {
+		return
+	}
+	n := len(b.chunks)
+	if pos < b.size() {
+		n, _ = b.locate(pos)
+	}
+	if n <= 0 {
+		return
+	}
+	pool := chunkPool(b.chunkSize)
+	for i := 0; i < n; i++ {
+		chunk := b.chunks[i]
+		b.length -= int64(len(chunk))
+		b.base += int64(len(chunk))
+		b.chunks[i] = nil
+		pool.Put(&chunk)
+	}
+	b.chunks = append(b.chunks[:0], b.chunks[n:]...)
+}
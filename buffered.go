@@ -0,0 +1,22 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// NewBufferedDoppelganger creates a new reader that acts like the original reader and reads ahead,
+// every read on the source it triggers requests at least size bytes, so small reads (e.g. ReadByte)
+// are served from the buffer of the factory instead of reading the source byte by byte.
+// There is no second buffer like a bufio.Reader on top of a doppelganger would add: the returned reader
+// is a regular doppelganger (it implements io.ByteScanner, io.RuneScanner, io.Seeker, Peek, ...)
+// and its position only includes the bytes that have been consumed.
+// Close removes the doppelganger from the factory.
+func NewBufferedDoppelganger(factory DoppelgangerFactory, size int) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	if instance, ok := reader.(*readerInstance); ok {
+		instance.DoppelBase.mu.Lock()
+		instance.readAhead = size
+		instance.DoppelBase.mu.Unlock()
+	}
+	return reader
+}
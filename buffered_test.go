@@ -0,0 +1,82 @@
+package doppelgangerreader_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+// requestRecorder records the sizes of the reads on the underlying reader
+type requestRecorder struct {
+	io.Reader
+	requests []int
+}
+
+func (r *requestRecorder) Read(p []byte) (int, error) {
+	r.requests = append(r.requests, len(p))
+	return r.Reader.Read(p)
+}
+
+func TestNewBufferedDoppelganger(t *testing.T) {
+	source := &requestRecorder{Reader: bytes.NewBufferString("Hello World")}
+	factory := doppelgangerreader.NewFactory(source)
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewBufferedDoppelganger(factory, 16)
+	r, ok := reader.(interface {
+		io.ByteScanner
+		Peek(n int) ([]byte, error)
+		Position() int64
+	})
+	if !ok {
+		t.Fatal("expected reader to implement io.ByteScanner, Peek and Position")
+	}
+
+	if b, err := r.ReadByte(); err != nil || b != 'H' {
+		t.Fatalf("expected %q, but got %q (%v)", 'H', b, err)
+	}
+	// the source has been read ahead, but the position only includes the consumed byte
+	if len(source.requests) != 1 || source.requests[0] < 16 {
+		t.Fatalf("expected a read of at least %d bytes, but got %v", 16, source.requests)
+	}
+	if pos := r.Position(); pos != 1 {
+		t.Fatalf("expected %d, but got %d", 1, pos)
+	}
+	if n := factory.BufferedBytes(); n != 11 {
+		t.Fatalf("expected %d, but got %d", 11, n)
+	}
+	if err := r.UnreadByte(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UnreadByte(); err != bufio.ErrInvalidUnreadByte {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadByte, err)
+	}
+	if buf, err := r.Peek(5); err != nil || string(buf) != "Hello" {
+		t.Fatalf("expected %q, but got %q (%v)", "Hello", buf, err)
+	}
+	if buf, _ := ioutil.ReadAll(reader); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+
+	// seeking and rewinding work on the position of the doppelganger
+	if err := reader.(interface{ Rewind() error }).Rewind(); err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := ioutil.ReadAll(reader); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+
+	if n := factory.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected %d, but got %d", 1, n)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected %d, but got %d", 0, n)
+	}
+}
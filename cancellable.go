@@ -0,0 +1,40 @@
+package doppelgangerreader
+
+import (
+	"context"
+	"io"
+)
+
+// NewCancellableDoppelganger creates a new reader that acts like the original reader
+// and calls cancel the first time Read returns an error of the source (other than io.EOF).
+// Errors that do not come from the source (e.g. ErrBufferFull) do not call cancel.
+func NewCancellableDoppelganger(factory DoppelgangerFactory, cancel context.CancelFunc) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	instance, ok := reader.(*readerInstance)
+	if !ok {
+		return reader
+	}
+	return &cancellableReader{
+		Reader:   reader,
+		Closer:   reader,
+		instance: instance,
+		cancel:   cancel,
+	}
+}
+
+type cancellableReader struct {
+	io.Reader
+	io.Closer
+	instance *readerInstance
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+func (r *cancellableReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF && !r.canceled && err == r.instance.sourceErr() {
+		r.canceled = true
+		r.cancel()
+	}
+	return n, err
+}
@@ -0,0 +1,49 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewCancellableDoppelganger(t *testing.T) {
+	t.Run("source error", func(t *testing.T) {
+		sourceErr := errors.New("connection reset")
+		factory := doppelgangerreader.NewFactory(io.MultiReader(bytes.NewBufferString("Hello"), &errorReader{sourceErr}))
+		defer factory.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reader := doppelgangerreader.NewCancellableDoppelganger(factory, cancel)
+		defer reader.Close()
+
+		if _, err := ioutil.ReadAll(reader); !errors.Is(err, sourceErr) {
+			t.Fatalf("expected %v, but got %v", sourceErr, err)
+		}
+		if err := ctx.Err(); err != context.Canceled {
+			t.Fatalf("expected %v, but got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("eof", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello"))
+		defer factory.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reader := doppelgangerreader.NewCancellableDoppelganger(factory, cancel)
+		defer reader.Close()
+
+		if _, err := ioutil.ReadAll(reader); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrSourceNotReaderAt will be reported by Prefetch if WithPrefetchConcurrency is greater than 1
+// and the original reader does not implement io.ReaderAt
+var ErrSourceNotReaderAt = errors.New("source does not implement io.ReaderAt")
+
+// concurrentReader reads the source with concurrent ReadAt calls on non-overlapping ranges,
+// the data is returned in the order of the source.
+type concurrentReader struct {
+	source      io.Reader
+	readerAt    io.ReaderAt
+	offset      int64
+	concurrency int
+	// parts holds the data that has been read but not returned yet
+	parts [][]byte
+	err   error
+}
+
+func newConcurrentReader(source io.Reader, readerAt io.ReaderAt, offset int64, concurrency int) *concurrentReader {
+	return &concurrentReader{
+		source:      source,
+		readerAt:    readerAt,
+		offset:      offset,
+		concurrency: concurrency,
+	}
+}
+
+func (r *concurrentReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.parts) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.readParts(len(p))
+	}
+	n := 0
+	for len(r.parts) > 0 && n < len(p) {
+		c := copy(p[n:], r.parts[0])
+		n += c
+		r.parts[0] = r.parts[0][c:]
+		if len(r.parts[0]) == 0 {
+			r.parts = r.parts[1:]
+		}
+	}
+	if n == 0 {
+		return 0, r.err
+	}
+	return n, nil
+}
+
+// readParts reads the next concurrency parts of size bytes at the same time.
+func (r *concurrentReader) readParts(size int) {
+	type result struct {
+		p   []byte
+		err error
+	}
+	results := make([]result, r.concurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := make([]byte, size)
+			n, err := r.readerAt.ReadAt(p, r.offset+int64(i*size))
+			results[i] = result{p[:n], err}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if len(result.p) > 0 {
+			r.parts = append(r.parts, result.p)
+			r.offset += int64(len(result.p))
+		}
+		if len(result.p) < size || result.err != nil {
+			// the following parts are beyond the end (or the error) of the source
+			r.err = result.err
+			if r.err == nil {
+				r.err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+	}
+}
+
+// Close closes the source (if it implements io.Closer), see WithCloseSource.
+func (r *concurrentReader) Close() error {
+	if closer, ok := r.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
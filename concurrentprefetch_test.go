@@ -0,0 +1,98 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+// concurrencyRecorder records the maximum number of concurrent ReadAt calls
+type concurrencyRecorder struct {
+	*bytes.Reader
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (r *concurrencyRecorder) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	r.current++
+	if r.current > r.max {
+		r.max = r.current
+	}
+	r.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	defer func() {
+		r.mu.Lock()
+		r.current--
+		r.mu.Unlock()
+	}()
+	return r.Reader.ReadAt(p, off)
+}
+
+func TestWithPrefetchConcurrency(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 1000)
+	source := &concurrencyRecorder{Reader: bytes.NewReader(payload)}
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		source,
+		doppelgangerreader.WithChunkSize(100),
+		doppelgangerreader.WithPrefetchConcurrency(4),
+	)
+	defer factory.Close()
+
+	// the first bytes are read sequentially
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	first := read(t, reader, 10)
+
+	if err := factory.Prefetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// wait for the prefetching, so the reader does not read the source sequentially
+	deadline := time.Now().Add(time.Second)
+	for !factory.SourceEOF() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected source to be at EOF")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf := append(first, rest...); !bytes.Equal(payload, buf) {
+		t.Fatalf("expected the payload in order, but got %d different bytes", len(buf))
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.max < 2 {
+		t.Fatalf("expected concurrent reads, but got %d", source.max)
+	}
+}
+
+func TestWithPrefetchConcurrencyNoReaderAt(t *testing.T) {
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewBufferString("Hello World"),
+		doppelgangerreader.WithPrefetchConcurrency(4),
+	)
+	defer factory.Close()
+
+	if err := factory.Prefetch(context.Background()); err != doppelgangerreader.ErrSourceNotReaderAt {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrSourceNotReaderAt, err)
+	}
+	// sequential prefetching works regardless
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewBufferString("Hello World"),
+		doppelgangerreader.WithPrefetchConcurrency(1),
+	)
+	defer factory.Close()
+	if err := factory.Prefetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+package doppelgangerreader
+
+import (
+	"context"
+	"io"
+)
+
+// ContextValueDoppelganger is a doppelganger that carries a context
+type ContextValueDoppelganger interface {
+	io.ReadCloser
+	// Context returns the context the doppelganger has been created with
+	Context() context.Context
+}
+
+// NewContextValueDoppelganger creates a new reader that acts like the original reader and carries ctx,
+// so it can be retrieved with Context wherever the doppelganger is passed to.
+// Unlike NewContextDoppelganger canceling ctx does not affect the reader. A nil ctx is replaced with context.Background().
+func NewContextValueDoppelganger(factory DoppelgangerFactory, ctx context.Context) ContextValueDoppelganger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &contextValueReader{
+		ReadCloser: factory.NewDoppelganger(),
+		ctx:        ctx,
+	}
+}
+
+type contextValueReader struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (r *contextValueReader) Context() context.Context {
+	return r.ctx
+}
@@ -0,0 +1,40 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+type contextKey struct{}
+
+func TestNewContextValueDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "request-1"))
+	reader := doppelgangerreader.NewContextValueDoppelganger(factory, ctx)
+	defer reader.Close()
+
+	if v := reader.Context().Value(contextKey{}); v != "request-1" {
+		t.Fatalf("expected %v, but got %v", "request-1", v)
+	}
+
+	// canceling the context does not affect the reader
+	cancel()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	if ctx := doppelgangerreader.NewContextValueDoppelganger(factory, nil).Context(); ctx != context.Background() {
+		t.Fatalf("expected %v, but got %v", context.Background(), ctx)
+	}
+}
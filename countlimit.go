@@ -0,0 +1,59 @@
+package doppelgangerreader
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewCountLimitedDoppelganger creates a new reader that acts like the original reader
+// but panics if more than maxReads successful (non-zero, non-error) Read calls are made.
+// This is meant as a test helper to ensure consumers do not over-read.
+func NewCountLimitedDoppelganger(factory DoppelgangerFactory, maxReads int) io.ReadCloser {
+	return newCountLimitedReader(factory.NewDoppelganger(), maxReads)
+}
+
+// NewNamedCountLimitedDoppelganger creates a count limited reader like NewCountLimitedDoppelganger
+// on top of a named doppelganger (see NewNamedDoppelganger), the panic message contains the name.
+func NewNamedCountLimitedDoppelganger(factory DoppelgangerFactory, name string, maxReads int) (io.ReadCloser, error) {
+	reader, err := factory.NewNamedDoppelganger(name)
+	if err != nil {
+		return nil, err
+	}
+	return newCountLimitedReader(reader, maxReads), nil
+}
+
+func newCountLimitedReader(reader io.ReadCloser, maxReads int) *countLimitedReader {
+	return &countLimitedReader{
+		Reader:   reader,
+		Closer:   reader,
+		maxReads: maxReads,
+	}
+}
+
+type countLimitedReader struct {
+	io.Reader
+	io.Closer
+	maxReads int
+	reads    int
+	offset   int64
+}
+
+func (r *countLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && err == nil {
+		r.reads++
+		if r.reads > r.maxReads {
+			panic(fmt.Sprintf("%s exceeded the limit of %d reads at offset %d", r.describe(), r.maxReads, r.offset))
+		}
+	}
+	r.offset += int64(n)
+	return n, err
+}
+
+// describe returns how the panic message refers to the doppelganger.
+func (r *countLimitedReader) describe() string {
+	if named, ok := r.Reader.(interface{ Name() string }); ok && named.Name() != "" {
+		return fmt.Sprintf("doppelganger %q", named.Name())
+	}
+	return "unnamed doppelganger"
+}
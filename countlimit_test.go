@@ -0,0 +1,67 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewCountLimitedDoppelganger(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewCountLimitedDoppelganger(factory, 2)
+	defer reader.Close()
+
+	readAtLeast(t, reader, 1)
+	readAtLeast(t, reader, 1)
+
+	err := recoverCountLimitPanic(t, reader)
+	if !strings.Contains(err, "offset 2") {
+		t.Fatalf("expected offset in panic message, but got %v", err)
+	}
+	if !strings.Contains(err, "unnamed doppelganger") {
+		t.Fatalf("expected unnamed doppelganger in panic message, but got %v", err)
+	}
+}
+
+func TestNewNamedCountLimitedDoppelganger(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader, err := doppelgangerreader.NewNamedCountLimitedDoppelganger(factory, "consumer", 1)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer reader.Close()
+
+	if _, err := doppelgangerreader.NewNamedCountLimitedDoppelganger(factory, "consumer", 1); err == nil {
+		t.Fatalf("expected error, but got %v", err)
+	}
+
+	readAtLeast(t, reader, 3)
+
+	msg := recoverCountLimitPanic(t, reader)
+	if !strings.Contains(msg, `doppelganger "consumer"`) {
+		t.Fatalf("expected name in panic message, but got %v", msg)
+	}
+	if !strings.Contains(msg, "offset 3") {
+		t.Fatalf("expected offset in panic message, but got %v", msg)
+	}
+}
+
+func recoverCountLimitPanic(t *testing.T, reader io.Reader) (msg string) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			t.Fatalf("expected panic")
+		}
+		msg = fmt.Sprint(err)
+	}()
+	_, _ = reader.Read(make([]byte, 1))
+	return ""
+}
@@ -0,0 +1,30 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// NewCryptoPrefixedDoppelganger creates a new reader that acts like the original reader
+// but prepends prefixLen cryptographically random bytes to the stream.
+// The generated prefix is returned alongside the reader, other doppelgangers will not see the prefix.
+func NewCryptoPrefixedDoppelganger(factory DoppelgangerFactory, prefixLen int) (io.ReadCloser, []byte, error) {
+	if prefixLen < 0 {
+		return nil, nil, errors.New("prefix length cannot be negative")
+	}
+	prefix := make([]byte, prefixLen)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, nil, err
+	}
+	reader, err := newDoppelgangerErr(factory)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &wrappedReader{
+		// copy the prefix so the caller cannot modify the stream
+		Reader: io.MultiReader(bytes.NewReader(append([]byte(nil), prefix...)), reader),
+		Closer: reader,
+	}, prefix, nil
+}
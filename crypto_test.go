@@ -0,0 +1,45 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewCryptoPrefixedDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader, prefix, err := doppelgangerreader.NewCryptoPrefixedDoppelganger(factory, 16)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer reader.Close()
+	if len(prefix) != 16 {
+		t.Fatalf("expected 16, but got %d", len(prefix))
+	}
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := append(append([]byte(nil), prefix...), payload...)
+	if !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %v, but got %v", expected, buf)
+	}
+
+	buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	if _, _, err := doppelgangerreader.NewCryptoPrefixedDoppelganger(factory, -1); err == nil {
+		t.Fatalf("expected error")
+	}
+}
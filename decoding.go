@@ -0,0 +1,47 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// NewDecodingDoppelganger creates a new reader that reads the original stream through the decoder
+// returned by newDecoder, other doppelgangers still see the original data.
+// It can be used for any decompressor, e.g. for xz:
+//
+//	NewDecodingDoppelganger(factory, func(r io.Reader) (io.Reader, error) {
+//		return xz.NewReader(r)
+//	})
+//
+// See NewBrotliDoppelganger for brotli.
+// If the decoder implements io.Closer it will be closed along with the doppelganger.
+func NewDecodingDoppelganger(factory DoppelgangerFactory, newDecoder func(r io.Reader) (io.Reader, error)) (io.ReadCloser, error) {
+	reader, err := newDoppelgangerErr(factory)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := newDecoder(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return &decodingReader{
+		Reader: decoder,
+		reader: reader,
+	}, nil
+}
+
+type decodingReader struct {
+	io.Reader
+	reader io.ReadCloser
+}
+
+func (r *decodingReader) Close() error {
+	var err error
+	if closer, ok := r.Reader.(io.Closer); ok {
+		err = closer.Close()
+	}
+	if cerr := r.reader.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
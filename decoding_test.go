@@ -0,0 +1,61 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewDecodingDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	var compressed bytes.Buffer
+	w, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	_, _ = w.Write(payload)
+	_ = w.Close()
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(compressed.Bytes()))
+	defer factory.Close()
+
+	reader, err := doppelgangerreader.NewDecodingDoppelganger(factory, func(r io.Reader) (io.Reader, error) {
+		return flate.NewReader(r), nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+	if err = reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// other doppelgangers see the compressed data
+	buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(compressed.Bytes(), buf) {
+		t.Fatalf("expected %v, but got %v", compressed.Bytes(), buf)
+	}
+
+	// errors of newDecoder are returned and the doppelganger is closed
+	count := factory.ActiveDoppelgangerCount()
+	decoderErr := errors.New("invalid header")
+	if _, err = doppelgangerreader.NewDecodingDoppelganger(factory, func(io.Reader) (io.Reader, error) {
+		return nil, decoderErr
+	}); err != decoderErr {
+		t.Fatalf("expected %v, but got %v", decoderErr, err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != count {
+		t.Fatalf("expected %d, but got %d", count, n)
+	}
+}
@@ -0,0 +1,74 @@
+package doppelgangerreader
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrDecompressionFailed will be reported if the source of a factory created with WithDecompression
+// is not compressed in the specified format
+var ErrDecompressionFailed = errors.New("decompression failed")
+
+// decompress wraps source so it will be decompressed in the specified format.
+func decompress(source io.Reader, format string) io.Reader {
+	if format == "" || format == "identity" {
+		return source
+	}
+	return &decompressingReader{
+		source: source,
+		format: format,
+	}
+}
+
+// decompressingReader initializes the decompressor on the first Read,
+// so creating a factory does not read from the source.
+type decompressingReader struct {
+	source       io.Reader
+	format       string
+	decompressor io.ReadCloser
+	err          error
+}
+
+func (r *decompressingReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.decompressor == nil {
+		decompressor, err := r.newDecompressor()
+		if err != nil {
+			r.err = fmt.Errorf("%w: %v", ErrDecompressionFailed, err)
+			return 0, r.err
+		}
+		r.decompressor = decompressor
+	}
+	return r.decompressor.Read(p)
+}
+
+func (r *decompressingReader) newDecompressor() (io.ReadCloser, error) {
+	switch r.format {
+	case "gzip":
+		return gzip.NewReader(r.source)
+	case "deflate":
+		// the deflate content encoding of HTTP is the zlib format
+		return zlib.NewReader(r.source)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", r.format)
+	}
+}
+
+// Close closes the decompressor and the source (if it implements io.Closer).
+func (r *decompressingReader) Close() error {
+	var err error
+	if r.decompressor != nil {
+		err = r.decompressor.Close()
+	}
+	if closer, ok := r.source.(io.Closer); ok {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
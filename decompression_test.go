@@ -0,0 +1,64 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestWithDecompression(t *testing.T) {
+	payload := []byte("Hello World")
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write(payload)
+	_ = gw.Close()
+
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	_, _ = zw.Write(payload)
+	_ = zw.Close()
+
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"gzip", gzipped.Bytes()},
+		{"deflate", deflated.Bytes()},
+		{"identity", payload},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(test.data), doppelgangerreader.WithDecompression(test.format))
+			defer factory.Close()
+
+			for i := 0; i < 2; i++ {
+				reader := factory.NewDoppelganger()
+				if buf, err := ioutil.ReadAll(reader); err != nil || !bytes.Equal(payload, buf) {
+					t.Fatalf("expected %v, but got %v (%v)", payload, buf, err)
+				}
+				_ = reader.Close()
+			}
+		})
+	}
+}
+
+func TestWithDecompressionFailed(t *testing.T) {
+	for _, format := range []string{"gzip", "deflate", "brotli"} {
+		t.Run(format, func(t *testing.T) {
+			factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewBufferString("Hello World"), doppelgangerreader.WithDecompression(format))
+			defer factory.Close()
+
+			reader := factory.NewDoppelganger()
+			defer reader.Close()
+			if _, err := reader.Read(make([]byte, 16)); !errors.Is(err, doppelgangerreader.ErrDecompressionFailed) {
+				t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDecompressionFailed, err)
+			}
+		})
+	}
+}
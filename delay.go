@@ -0,0 +1,33 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"time"
+)
+
+// NewDelayedDoppelganger creates a new reader that acts like the original reader
+// but blocks the first Read for at least delay, subsequent reads are not delayed.
+// It can be used to simulate a consumer that arrives late to the stream.
+func NewDelayedDoppelganger(factory DoppelgangerFactory, delay time.Duration) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &delayedReader{
+		Reader: reader,
+		Closer: reader,
+		delay:  delay,
+	}
+}
+
+type delayedReader struct {
+	io.Reader
+	io.Closer
+	delay   time.Duration
+	started bool
+}
+
+func (r *delayedReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		time.Sleep(r.delay)
+	}
+	return r.Reader.Read(p)
+}
@@ -0,0 +1,40 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewDelayedDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	delayed := doppelgangerreader.NewDelayedDoppelganger(factory, time.Millisecond*50)
+	defer delayed.Close()
+
+	// another reader consumes the stream in the meantime
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	start := time.Now()
+	buf, err = ioutil.ReadAll(delayed)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if d := time.Since(start); d < time.Millisecond*50 {
+		t.Fatalf("expected at least %v, but got %v", time.Millisecond*50, d)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
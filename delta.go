@@ -0,0 +1,89 @@
+package doppelgangerreader
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// NewDeltaDoppelganger creates a new reader that delta encodes the original stream,
+// every big endian unsigned integer of valueSize (1, 2, 4 or 8) bytes is replaced with the difference
+// to the previous value (wrapping around on overflow), the first value is delivered as is.
+// Trailing bytes that do not form a whole value are delivered unchanged.
+// It panics if valueSize is not supported.
+func NewDeltaDoppelganger(factory DoppelgangerFactory, valueSize int) io.ReadCloser {
+	if valueSize != 1 && valueSize != 2 && valueSize != 4 && valueSize != 8 {
+		panic("value size must be 1, 2, 4 or 8")
+	}
+	reader := factory.NewDoppelganger()
+	return &deltaReader{
+		Reader:    reader,
+		Closer:    reader,
+		valueSize: valueSize,
+	}
+}
+
+type deltaReader struct {
+	io.Reader
+	io.Closer
+	valueSize int
+	previous  uint64
+	// pending holds the bytes of a value that has not been read completely
+	pending []byte
+	// encoded holds the encoded data that has not been delivered yet
+	encoded []byte
+	err     error
+}
+
+func (r *deltaReader) Read(p []byte) (int, error) {
+	for len(r.encoded) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		size := len(p)
+		if size < r.valueSize {
+			size = r.valueSize
+		}
+		buf := make([]byte, size)
+		n, err := r.Reader.Read(buf)
+		r.encode(buf[:n])
+		if err != nil {
+			r.err = err
+			// deliver the incomplete value as is
+			r.encoded = append(r.encoded, r.pending...)
+			r.pending = nil
+		}
+	}
+	n := copy(p, r.encoded)
+	r.encoded = r.encoded[n:]
+	return n, nil
+}
+
+func (r *deltaReader) encode(p []byte) {
+	data := append(r.pending, p...)
+	for len(data) >= r.valueSize {
+		value := r.decodeValue(data[:r.valueSize])
+		r.encoded = r.appendValue(r.encoded, value-r.previous)
+		r.previous = value
+		data = data[r.valueSize:]
+	}
+	r.pending = append(r.pending[:0], data...)
+}
+
+func (r *deltaReader) decodeValue(p []byte) uint64 {
+	switch r.valueSize {
+	case 1:
+		return uint64(p[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(p))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(p))
+	default:
+		return binary.BigEndian.Uint64(p)
+	}
+}
+
+func (r *deltaReader) appendValue(p []byte, value uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], value)
+	return append(p, buf[8-r.valueSize:]...)
+}
@@ -0,0 +1,40 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewDeltaDoppelganger(t *testing.T) {
+	// 1000, 1003, 1010 and a trailing byte
+	payload := []byte{0x03, 0xe8, 0x03, 0xeb, 0x03, 0xf2, 0xff}
+	factory := doppelgangerreader.NewFactory(iotest.HalfReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewDeltaDoppelganger(factory, 2)
+	defer reader.Close()
+
+	buf, err := ioutil.ReadAll(iotest.OneByteReader(reader))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	expected := []byte{0x03, 0xe8, 0x00, 0x03, 0x00, 0x07, 0xff}
+	if !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %v, but got %v", expected, buf)
+	}
+
+	// decreasing values wrap around
+	factory = doppelgangerreader.NewFactory(bytes.NewReader([]byte{5, 3}))
+	defer factory.Close()
+	buf, err = ioutil.ReadAll(doppelgangerreader.NewDeltaDoppelganger(factory, 1))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if expected := []byte{5, 0xfe}; !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %v, but got %v", expected, buf)
+	}
+}
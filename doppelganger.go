@@ -1,208 +1,2329 @@
 package doppelgangerreader
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 // DoppelgangerFactory is a reader that mimics the behaviour of an other reader
-// it can be used to read readers multiple times
+// it can be used to read readers multiple times.
+// Doppelgangers with additional behavior (e.g. NewHexDoppelganger, NewPacedDoppelganger) are created with the
+// package level functions that take a DoppelgangerFactory.
 type DoppelgangerFactory interface {
 	NewDoppelganger() io.ReadCloser
+	NewDoppelgangerErr() (io.ReadCloser, error)
+	NewContextDoppelganger(ctx context.Context) io.ReadCloser
+	NewDoppelgangerAt(offset int64) (io.ReadCloser, error)
+	Tail(n int64) (io.ReadCloser, error)
+	NewAsyncCloseDoppelganger() io.ReadCloser
+	NewNamedDoppelganger(name string) (io.ReadCloser, error)
+	GetDoppelganger(name string) (io.ReadCloser, bool)
+	RemoveDoppelgangerByName(name string) error
 	RemoveDoppelganger(r io.ReadCloser) error
+	RemoveDoppelgangerReader(r io.Reader) error
+	CloseAllDoppelgangers() error
+	BufferSize() int64
+	BufferedBytes() int64
+	Bytes() []byte
+	Stats() FactoryStats
+	SnapshotReader() io.Reader
+	SourceEOF() bool
+	Remaining() (int64, bool)
+	SumHash(b []byte) []byte
+	ActiveDoppelgangerCount() int
+	ListDoppelgangers() []io.ReadCloser
+	Prefetch(ctx context.Context) error
+	Drain() error
+	WaitForAsyncCloses(ctx context.Context) error
+	WaitAll(ctx context.Context) error
+	ReadAt(p []byte, off int64) (int, error)
+	TeeError() error
+	Fork(startOffset int64) (DoppelgangerFactory, error)
+	Copy(dst DoppelgangerFactory) error
+	Reset(r io.Reader) error
+	Freeze() error
+	NewBarrier(offset int64) *Barrier
+	Trim(upToOffset int64) error
+	TrimWatermark() int64
+	Subscribe(ch chan<- []byte) (cancel func(), err error)
+	WriteTo(w io.Writer) (int64, error)
 	Close() error
+	MustClose()
+	CloseAndLog(logger Logger)
+}
+
+// Logger is used by CloseAndLog to report errors, it is implemented by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Doppelganger is implemented by the readers a DoppelgangerFactory creates,
+// it allows to detect a doppelganger behind an io.ReadCloser:
+//
+//	if d, ok := r.(Doppelganger); ok {
+//		d.Rewind()
+//	}
+type Doppelganger interface {
+	IsDoppelganger() bool
+	Position() int64
+	Rewind() error
+	Peek(n int) ([]byte, error)
 }
 
 // NewFactory creates a new DoppelgangerFactory with the original reader specified
-// if the reader is already a Doppelganger it will return the original factory
+// if the reader is already a Doppelganger it will return the original factory,
+// it is a shortcut for NewFactoryWithOptions without options.
 func NewFactory(readerToMimic io.Reader) DoppelgangerFactory {
-	factory := GetFactory(readerToMimic)
-	if factory != nil {
-		return &nestedDoppelgangerFactory{
-			parent: factory,
-		}
+	return NewFactoryWithOptions(readerToMimic)
+}
+
+// NewFactoryWithData creates a new DoppelgangerFactory whose buffer already holds prefix,
+// every doppelganger sees prefix followed by the data of the original reader.
+// If prefix is empty it behaves like NewFactory.
+func NewFactoryWithData(prefix []byte, readerToMimic io.Reader) DoppelgangerFactory {
+	if len(prefix) == 0 {
+		return NewFactory(readerToMimic)
 	}
-	return &doppelgangerFactory{
+	factory := &doppelgangerFactory{
 		source: readerToMimic,
 	}
+	factory.buffer.write(prefix)
+	factory.prefixSize = int64(len(prefix))
+	factory.limitSource()
+	return factory
 }
 
 type doppelgangerFactory struct {
-	source   io.Reader
-	readers  []*readerInstance
-	buffer   bytes.Buffer
-	mu       sync.Mutex
-	closedOn *int
+	config  factoryConfig
+	source  io.Reader
+	readers []*readerInstance
+	buffer  chunkedBuffer
+	// mu protects the state of the factory and its readers, the doppelgangers of a frozen factory
+	// read the buffer while only holding the read lock, see Freeze
+	mu factoryMutex
+	// frozen is set (atomically) by Freeze
+	frozen int32
+	// closed is set once the factory has been closed, no more data will be read from the source
+	closed bool
+	// err holds the error the source returned, it will be reported to every reader
+	// that reached the end of the buffer
+	err error
+	// fetching is set while a read on the source is in progress
+	fetching    bool
+	prefetching bool
+	scratch     []byte
+	// notify will be closed (and replaced) every time the state of the factory changes
+	notify chan struct{}
+	// pins is the number of readers that use the buffer without holding the lock
+	pins int
+	// asyncCloses tracks the pending closes of async close doppelgangers
+	asyncCloses pendingCloses
+	// waiters is the number of WaitAll calls that wait for the readers to move
+	waiters int
+	// tees are the writers of WithTee that did not fail yet
+	tees   []io.Writer
+	teeErr error
+	// lastProgress is the time of the last WithProgressFunc call
+	lastProgress time.Time
+	// generation is incremented by Reset
+	generation int
+	// rateTokens and rateLast are the state of the token bucket of WithReadRate
+	rateTokens float64
+	rateLast   time.Time
+	// closedCh is closed when the factory is closed, see closedChan
+	closedCh chan struct{}
+	// prefixSize is the number of bytes that have been passed to NewFactoryWithData
+	prefixSize int64
+	// trimWatermark is the position Trim released the data before
+	trimWatermark int64
+	// subscribers receive the chunks read from the source, see Subscribe
+	subscribers []*subscriber
+	// ownedSource is the doppelganger Copy and Fork use as source, it is closed along with the factory (or by Reset)
+	ownedSource io.Closer
+	// closedReaders is the number of doppelgangers that have been closed or removed, see Stats
+	closedReaders int
+	// limited is set if the source is an *io.LimitedReader, limitEnd is the position in the buffer
+	// its limit ends at, see Remaining
+	limited  bool
+	limitEnd int64
 }
 
 // NewDoppelganger creates a new reader that acts like the original reader
+// the returned reader also implements io.Seeker, io.ByteScanner, io.RuneScanner, io.WriterTo, Peek, Discard, ReadBytes, ReadString
+// and SetReadDeadline.
+// If the limit of WithMaxDoppelgangers is reached it panics or blocks, see WithMaxDoppelgangersPolicy.
 func (factory *doppelgangerFactory) NewDoppelganger() io.ReadCloser {
+	return factory.mustNewReaderInstance(nil)
+}
+
+// NewDoppelgangerErr creates a new reader like NewDoppelganger,
+// but returns ErrTooManyDoppelgangers if the limit of WithMaxDoppelgangers is reached.
+func (factory *doppelgangerFactory) NewDoppelgangerErr() (io.ReadCloser, error) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.limitReached() {
+		return nil, ErrTooManyDoppelgangers
+	}
+	return factory.addReader(nil), nil
+}
+
+// NewContextDoppelganger creates a new reader that acts like the original reader,
+// if ctx is canceled while the reader waits for data from the source Read returns ctx.Err().
+// Canceling ctx does not affect other doppelgangers or the factory.
+func (factory *doppelgangerFactory) NewContextDoppelganger(ctx context.Context) io.ReadCloser {
+	return factory.mustNewReaderInstance(ctx)
+}
+
+// NewDoppelgangerAt creates a new reader that acts like the original reader but starts at offset.
+// If offset has not been buffered yet the data will be read from the source,
+// io.EOF is returned if the source ends before offset.
+// If the limit of WithMaxDoppelgangers is reached it returns ErrTooManyDoppelgangers or blocks,
+// see WithMaxDoppelgangersPolicy.
+func (factory *doppelgangerFactory) NewDoppelgangerAt(offset int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, errors.New("negative offset")
+	}
+	reader, err := factory.newReaderInstance(nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		_ = reader.Close()
+		if err == ErrSeekBeyondEnd {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Tail creates a new reader that acts like the original reader but only returns the last n bytes of the stream.
+// The source will be drained first, the error of the source is returned if that fails.
+// If n is greater than the length of the stream the reader starts at the beginning.
+// With WithBufferEviction the last n bytes might already have been evicted (e.g. after all doppelgangers
+// read the whole stream), ErrEvicted is returned in that case. Keep a doppelganger open at (or before)
+// the start of the tail to prevent that.
+func (factory *doppelgangerFactory) Tail(n int64) (io.ReadCloser, error) {
+	if n < 0 {
+		return nil, errors.New("negative length")
+	}
 	factory.mu.Lock()
+	if err := factory.drain(nil); err != nil {
+		factory.mu.Unlock()
+		return nil, err
+	}
+	offset := factory.size() - n
+	factory.mu.Unlock()
+	if offset < 0 {
+		offset = 0
+	}
+	return factory.NewDoppelgangerAt(offset)
+}
+
+// newReaderInstance creates a new reader labeled with name (can be empty), if the limit of WithMaxDoppelgangers
+// is reached it returns ErrTooManyDoppelgangers or blocks, see WithMaxDoppelgangersPolicy.
+// An error is returned if name is already in use.
+func (factory *doppelgangerFactory) newReaderInstance(ctx context.Context, name string) (*readerInstance, error) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	for {
+		if factory.find(name) != nil {
+			return nil, fmt.Errorf("doppelganger %q already exists", name)
+		}
+		if !factory.limitReached() {
+			break
+		}
+		// a WithNoLock factory has no other goroutine that could close a doppelganger
+		if factory.config.maxDoppelgangersPolicy != BlockOnMaxDoppelgangers || factory.mu.noLock {
+			return nil, ErrTooManyDoppelgangers
+		}
+		// wait until a doppelganger has been closed
+		factory.wait(nil)
+	}
+	reader := factory.addReader(ctx)
+	reader.name = name
+	return reader, nil
+}
+
+// newDoppelgangerErr creates a new reader like factory.NewDoppelganger for the constructors with an error return,
+// it returns ErrTooManyDoppelgangers instead of panicking.
+func newDoppelgangerErr(factory DoppelgangerFactory) (io.ReadCloser, error) {
+	var base *doppelgangerFactory
+	switch f := factory.(type) {
+	case *doppelgangerFactory:
+		base = f
+	case *multiFactory:
+		base = f.doppelgangerFactory
+	case *nestedDoppelgangerFactory:
+		r, err := newDoppelgangerErr(f.parent)
+		if err != nil {
+			return nil, err
+		}
+		return f.track(r), nil
+	default:
+		return factory.NewDoppelganger(), nil
+	}
+	reader, err := base.newReaderInstance(nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// mustNewReaderInstance is newReaderInstance for the constructors without error return, it panics instead.
+func (factory *doppelgangerFactory) mustNewReaderInstance(ctx context.Context) *readerInstance {
+	reader, err := factory.newReaderInstance(ctx, "")
+	if err != nil {
+		panic(err.Error())
+	}
+	return reader
+}
+
+// limitReached returns true if no more readers can be added because of WithMaxDoppelgangers.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) limitReached() bool {
+	return !factory.closed && factory.config.maxDoppelgangers > 0 && len(factory.readers) >= factory.config.maxDoppelgangers
+}
+
+// addReader creates a new reader and adds it to the active readers.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) addReader(ctx context.Context) *readerInstance {
 	reader := &readerInstance{
 		DoppelBase: factory,
-		// prefill Buffer with already collected data
-		Buffer: bytes.NewBuffer(factory.buffer.Bytes()),
+		ctx:        ctx,
+		// start at the oldest data that is still available
+		pos: factory.start(),
 	}
-	if factory.closedOn == nil {
+	if !factory.closed {
 		// only add to readers if there is still data to consume
 		factory.readers = append(factory.readers, reader)
 	}
-	factory.mu.Unlock()
 	return reader
 }
 
+// Fork creates a child factory for the stream starting at startOffset, the doppelgangers of the child
+// see the byte at startOffset as their first byte. The child reads its data from this factory
+// (not from the original reader), closing the child does not affect this factory.
+// io.EOF is returned if the source ends before startOffset.
+func (factory *doppelgangerFactory) Fork(startOffset int64) (DoppelgangerFactory, error) {
+	reader, err := factory.NewDoppelgangerAt(startOffset)
+	if err != nil {
+		return nil, err
+	}
+	child := &doppelgangerFactory{
+		source: reader,
+		// closing (or resetting) the child releases its doppelganger of this factory
+		ownedSource: reader,
+	}
+	child.config.chunkSize = factory.config.chunkSize
+	child.buffer.chunkSize = factory.config.chunkSize
+	return child, nil
+}
+
+// Copy copies the buffered data into dst and lets dst continue with the data this factory reads afterwards
+// (through a doppelganger of this factory that is closed along with dst), unlike Fork the buffer is not shared.
+// The active doppelgangers of dst keep their position and read the copied data from there on,
+// doppelgangers that are waiting for the source of dst fail with ErrFactoryReset.
+// The old source of dst will not be closed.
+// dst must not be a nested factory, ErrEvicted is returned if data has been evicted from the buffer of this factory.
+func (factory *doppelgangerFactory) Copy(dst DoppelgangerFactory) error {
+	target, ok := dst.(*doppelgangerFactory)
+	if !ok {
+		return errors.New("cannot copy into a nested factory")
+	}
+	if target == factory {
+		return errors.New("cannot copy into the same factory")
+	}
+
+	factory.mu.Lock()
+	if factory.closed {
+		factory.mu.Unlock()
+		return ErrFactoryClosed
+	}
+	if factory.start() > 0 {
+		factory.mu.Unlock()
+		return ErrEvicted
+	}
+	data := factory.buffer.bytes()
+	prefixSize := factory.prefixSize
+	source := factory.addReader(nil)
+	source.pos = factory.size()
+	factory.mu.Unlock()
+
+	target.mu.Lock()
+	var err error
+	switch {
+	case target.closed:
+		err = ErrFactoryClosed
+	case target.isFrozen():
+		err = ErrFactoryFrozen
+	}
+	if err != nil {
+		target.mu.Unlock()
+		_ = source.Close()
+		return err
+	}
+	if target.pins == 0 {
+		// release the chunks to the pool
+		target.buffer.evict(target.size())
+	}
+	target.buffer = chunkedBuffer{chunkSize: target.buffer.chunkSize}
+	target.buffer.write(data)
+	target.prefixSize = prefixSize
+	target.trimWatermark = 0
+	if target.config.hasher != nil {
+		target.config.hasher.Reset()
+		_, _ = target.config.hasher.Write(data)
+	}
+
+	target.switchSource(source)
+	previous := target.ownedSource
+	target.ownedSource = source
+	target.broadcast()
+	target.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.Close()
+	}
+	return nil
+}
+
+// NewNamedDoppelganger creates a new reader like NewDoppelganger and labels it with name,
+// the name can be retrieved with the Name method of the reader. Names must be unique among
+// the active doppelgangers of the factory, an error is returned if name is already in use.
+func (factory *doppelgangerFactory) NewNamedDoppelganger(name string) (io.ReadCloser, error) {
+	reader, err := factory.newReaderInstance(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// GetDoppelganger returns the active doppelganger that has been created with NewNamedDoppelganger(name)
+func (factory *doppelgangerFactory) GetDoppelganger(name string) (io.ReadCloser, bool) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if reader := factory.find(name); reader != nil {
+		return reader, true
+	}
+	return nil, false
+}
+
+// RemoveDoppelgangerByName removes the doppelganger that has been created with NewNamedDoppelganger(name),
+// see RemoveDoppelganger
+func (factory *doppelgangerFactory) RemoveDoppelgangerByName(name string) error {
+	reader, ok := factory.GetDoppelganger(name)
+	if !ok {
+		return fmt.Errorf("doppelganger %q not found", name)
+	}
+	return factory.RemoveDoppelganger(reader)
+}
+
+// find returns the active reader with the specified name.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) find(name string) *readerInstance {
+	for _, reader := range factory.readers {
+		if reader.name == name && name != "" {
+			return reader
+		}
+	}
+	return nil
+}
+
 // RemoveDoppelganger a created reader from receiving new data
 func (factory *doppelgangerFactory) RemoveDoppelganger(r io.ReadCloser) error {
+	return factory.RemoveDoppelgangerReader(r)
+}
+
+// RemoveDoppelgangerReader works like RemoveDoppelganger, but accepts a doppelganger
+// that has been passed around as an io.Reader
+func (factory *doppelgangerFactory) RemoveDoppelgangerReader(r io.Reader) error {
 	instance, ok := r.(*readerInstance)
 	if !ok {
 		return errors.New("not a reader instance")
 	}
 	factory.mu.Lock()
 	defer factory.mu.Unlock()
+	if !factory.removeReader(instance) {
+		return errors.New("reader not found")
+	}
+	return nil
+}
+
+// removeReader closes instance and removes it from the active readers,
+// it returns false if instance is not an active reader.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) removeReader(instance *readerInstance) bool {
 	for i := len(factory.readers) - 1; i >= 0; i-- {
 		if factory.readers[i] == instance {
-			factory.readers[i].DoppelBase = nil
+			instance.closed = true
+			instance.end()
+			factory.closedReaders++
+			if instance.deadline != nil {
+				instance.deadline.timer.Stop()
+			}
 			factory.readers = append(factory.readers[:i], factory.readers[i+1:]...)
-			return nil
+			if factory.config.bufferEviction {
+				factory.evict()
+			}
+			factory.broadcast()
+			return true
 		}
 	}
+	return false
+}
 
-	return errors.New("reader not found")
+// CloseAllDoppelgangers closes all active doppelgangers, the factory stays open so new doppelgangers
+// can be created afterwards. The first error that occurred is returned.
+func (factory *doppelgangerFactory) CloseAllDoppelgangers() error {
+	return closeAll(factory.ListDoppelgangers())
 }
 
-// Close the DoppelgangerFactory and stops all created Doppelgangers from receiving data
-// (does not close the underlying reader)
-func (factory *doppelgangerFactory) Close() error {
-	// this is a public function so make sure we lock
+func closeAll(readers []io.ReadCloser) error {
+	var errs []error
+	for _, reader := range readers {
+		if err := reader.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return combineErrors(errs)
+}
+
+// ReadAt reads len(p) bytes of the original reader starting at offset off, it implements the io.ReaderAt interface.
+// ReadAt does not change the position of any doppelganger and can be called concurrently,
+// the lock of the factory is not held while waiting for the source. If the requested range has not been
+// buffered yet the data will be read from the source.
+// If the source ended (or the factory was closed) before the range could be read, the buffered data
+// is returned with io.EOF (or the error of the source). io.EOF is also returned if off is at or beyond
+// the end of the stream, even if p is empty.
+func (factory *doppelgangerFactory) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
 	factory.mu.Lock()
-	err := factory.close()
+	defer factory.mu.Unlock()
+	size := int64(len(p))
+	if size == 0 {
+		// we need at least one byte to know whether off is at the end of the stream
+		size = 1
+	}
+	if err := factory.fillBuffer(off+size, 0, nil); err != nil && err != io.EOF && err != factory.err {
+		return 0, err
+	}
+	if off < factory.start() {
+		return 0, ErrEvicted
+	}
+	n := 0
+	if off < factory.size() {
+		n = factory.buffer.copyAt(p, off)
+	}
+	if n < len(p) || off >= factory.size() {
+		if factory.err != nil && factory.err != io.EOF {
+			return n, factory.err
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteTo writes the whole stream to w, it implements the io.WriterTo interface.
+// The buffered data is written first, the remaining data is read from the source and buffered
+// for the doppelgangers like it would be for any doppelganger. While WriteTo is running
+// it is listed as an active doppelganger. It returns ErrEvicted if the beginning of the stream has been evicted.
+func (factory *doppelgangerFactory) WriteTo(w io.Writer) (int64, error) {
+	factory.mu.Lock()
+	if factory.start() > 0 {
+		factory.mu.Unlock()
+		return 0, ErrEvicted
+	}
+	reader := factory.addReader(nil)
 	factory.mu.Unlock()
-	return err
+	defer reader.Close()
+	return reader.WriteTo(w)
 }
 
-// close the DoppelgangerFactory and stops all created Doppelgangers from receiving data
-// (does not close the underlying reader)
-func (factory *doppelgangerFactory) close() error {
-	// we already closed
-	if factory.closedOn != nil {
+// Trim releases the buffered data before upToOffset, afterwards no doppelganger can read (or seek to)
+// data before upToOffset. Only whole chunks of the buffer can be released (see WithChunkSize)
+// and upToOffset is limited to the number of bytes that have been read from the source.
+// It returns ErrDoppelgangerBehindTrimPoint without releasing anything if an active doppelganger
+// has not read up to upToOffset yet.
+func (factory *doppelgangerFactory) Trim(upToOffset int64) error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	for _, reader := range factory.readers {
+		if reader.pos < upToOffset {
+			return ErrDoppelgangerBehindTrimPoint
+		}
+	}
+	if size := factory.size(); upToOffset > size {
+		// data that has not been read from the source cannot be released
+		upToOffset = size
+	}
+	if upToOffset <= factory.trimWatermark {
 		return nil
 	}
-	factory.closedOn = new(int)
-	*factory.closedOn = factory.buffer.Len()
-
-	// remove all readers because everything has been consumed
-	factory.readers = nil
+	factory.trimWatermark = upToOffset
+	factory.trim()
 	return nil
 }
 
-func (factory *doppelgangerFactory) read(caller *readerInstance, p []byte) (int, error) {
-	if factory.closedOn != nil {
-		return 0, io.EOF
-	}
-	if factory.source == nil {
-		return 0, NilReaderError{}
+// trim releases the buffered data before the trim watermark.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) trim() {
+	// we cannot evict while someone uses the buffer without holding the lock,
+	// moved will release the data once they are done
+	if factory.pins > 0 || factory.trimWatermark <= factory.buffer.base {
+		return
 	}
-	n, err := factory.source.Read(p)
+	factory.buffer.evict(factory.trimWatermark)
+}
 
-	if n > 0 {
-		// fill my own Buffer if we have data
-		factory.buffer.Write(p[:n])
+// TrimWatermark returns the offset of the last Trim, 0 if the factory has not been trimmed
+func (factory *doppelgangerFactory) TrimWatermark() int64 {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.trimWatermark
+}
+
+// BufferSize returns the number of buffered bytes that have not been read by the slowest doppelganger yet.
+// This is the size that is limited by WithMaxBufferSize.
+func (factory *doppelgangerFactory) BufferSize() int64 {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.bufferSize()
+}
+
+// BufferedBytes returns the number of bytes that have been read from the source into the buffer
+// (evicted bytes are not included)
+func (factory *doppelgangerFactory) BufferedBytes() int64 {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.buffer.length
+}
+
+// Bytes returns a copy of all bytes that have been read from the source so far
+// (evicted bytes are not included), it does not read from the source.
+func (factory *doppelgangerFactory) Bytes() []byte {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.buffer.bytes()
+}
+
+// SnapshotReader returns a reader for a copy of the bytes that are buffered right now (see Bytes),
+// it never reads from the source. The reader is not a doppelganger, so it does not hold back eviction.
+func (factory *doppelgangerFactory) SnapshotReader() io.Reader {
+	return bytes.NewReader(factory.Bytes())
+}
+
+// SourceEOF returns true if the source reported io.EOF (or any other error),
+// regardless of whether the doppelgangers consumed all buffered data.
+func (factory *doppelgangerFactory) SourceEOF() bool {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.err != nil
+}
+
+// Remaining returns the number of bytes that are left to read from the source,
+// the second return value is false if the source is not an *io.LimitedReader
+// (or is wrapped, e.g. by WithDecompression) and the number is unknown.
+// The source might end before it reaches its limit.
+func (factory *doppelgangerFactory) Remaining() (int64, bool) {
+	factory.mu.RLock()
+	defer factory.mu.RUnlock()
+	if !factory.limited {
+		return 0, false
+	}
+	if factory.err != nil || factory.limitEnd < factory.size() {
+		return 0, true
 	}
+	return factory.limitEnd - factory.size(), true
+}
 
-	if err != nil {
-		return n, err
+// limitSource remembers the limit of the source if it is an *io.LimitedReader,
+// the limit is captured once because the source is read without holding the lock.
+// factory.mu must be held (or the factory must not be shared yet).
+func (factory *doppelgangerFactory) limitSource() {
+	factory.limited = false
+	if lr, ok := factory.source.(*io.LimitedReader); ok {
+		factory.limited = true
+		factory.limitEnd = factory.size() + lr.N
 	}
+}
 
-	for i := len(factory.readers) - 1; i >= 0; i-- {
-		if factory.readers[i] != caller {
-			factory.readers[i].Buffer.Write(p[:n])
-		}
+// SumHash appends the hash of all bytes that have been read from the source so far to b,
+// see WithHasher. If no hasher has been configured b is returned unchanged.
+func (factory *doppelgangerFactory) SumHash(b []byte) []byte {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.config.hasher == nil {
+		return b
 	}
-	return n, nil
+	return factory.config.hasher.Sum(b)
 }
 
-type readerInstance struct {
-	DoppelBase *doppelgangerFactory
-	Buffer     *bytes.Buffer
+// ActiveDoppelgangerCount returns the number of doppelgangers that have not been closed
+func (factory *doppelgangerFactory) ActiveDoppelgangerCount() int {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return len(factory.readers)
 }
 
-func (r *readerInstance) Read(p []byte) (n int, err error) {
-	if r.DoppelBase == nil {
-		return 0, io.EOF
+// ListDoppelgangers returns a snapshot of the doppelgangers that have not been closed
+func (factory *doppelgangerFactory) ListDoppelgangers() []io.ReadCloser {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	readers := make([]io.ReadCloser, len(factory.readers))
+	for i, reader := range factory.readers {
+		readers[i] = reader
 	}
-	r.DoppelBase.mu.Lock()
-	if r.Buffer.Len() > 0 {
-		n, err = r.Buffer.Read(p)
-	} else {
-		n, err = r.DoppelBase.read(r, p)
-		if err != nil {
-			r.DoppelBase.close()
+	return readers
+}
+
+// Prefetch starts reading the source until the end in the background, so the doppelgangers
+// do not have to wait for the source. Prefetching stops when ctx is canceled.
+// Errors of the source will be reported by the doppelgangers once they reach the position of the error.
+// Calling Prefetch again has no effect.
+// With WithPrefetchConcurrency the source is read with concurrent ReadAt calls, ErrSourceNotReaderAt is returned
+// if the original reader does not implement io.ReaderAt.
+func (factory *doppelgangerFactory) Prefetch(ctx context.Context) error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.closed {
+		return ErrFactoryClosed
+	}
+	if factory.prefetching {
+		return nil
+	}
+	var readerAt io.ReaderAt
+	if _, ok := factory.source.(*concurrentReader); !ok && factory.config.prefetchConcurrency > 1 {
+		if readerAt, ok = factory.source.(io.ReaderAt); !ok {
+			return ErrSourceNotReaderAt
 		}
 	}
-	r.DoppelBase.mu.Unlock()
-	return n, err
+	factory.prefetching = true
+	go func(generation int) {
+		factory.mu.Lock()
+		defer factory.mu.Unlock()
+		if readerAt != nil {
+			// the offset of the source is only known while no read is in progress
+			for factory.fetching && generation == factory.generation {
+				if !factory.wait(ctx.Done()) {
+					return
+				}
+			}
+			if generation != factory.generation {
+				return
+			}
+			offset := factory.size() - factory.prefixSize
+			factory.source = newConcurrentReader(factory.source, readerAt, offset, factory.config.prefetchConcurrency)
+		}
+		_ = factory.drain(ctx.Done())
+	}(factory.generation)
+	return nil
 }
 
-func (r *readerInstance) Close() error {
-	// if the factory is already closed
-	// we dont need to remove
-	if r.DoppelBase.closedOn == nil {
-		return r.DoppelBase.RemoveDoppelganger(r)
+// WaitAll blocks until every active doppelganger (including the ones created while waiting)
+// has been closed or read to the end of the stream, it does not read from the source.
+// It returns ctx.Err() if ctx is done before.
+func (factory *doppelgangerFactory) WaitAll(ctx context.Context) error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	factory.waiters++
+	defer func() {
+		factory.waiters--
+	}()
+	for !factory.finished() {
+		if !factory.wait(ctx.Done()) {
+			return ctx.Err()
+		}
 	}
 	return nil
 }
 
-// NilReaderError will be reported if the provided reader is nil
-type NilReaderError struct{}
+// finished returns true if all active readers reached the end of the stream.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) finished() bool {
+	if factory.closed {
+		return true
+	}
+	for _, reader := range factory.readers {
+		if factory.err == nil || reader.pos < factory.size() {
+			return false
+		}
+	}
+	return true
+}
 
-// Error returns the error message
-func (NilReaderError) Error() string {
-	return "Reader to mimic is nil"
+// Drain reads the source until the end, so all doppelgangers can be served from the buffer.
+// It returns the error of the source (except io.EOF), calling Drain again has no effect.
+func (factory *doppelgangerFactory) Drain() error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.drain(nil)
 }
 
-// IsNilReaderError returns true if the specified error is a NilReaderError
-func IsNilReaderError(e error) bool {
-	_, ok := e.(NilReaderError)
-	return ok
+// drain reads the source until the end, see fillBuffer for done.
+// factory.mu must be held, it will be released while reading from the source.
+func (factory *doppelgangerFactory) drain(done <-chan struct{}) error {
+	for {
+		err := factory.fillBuffer(factory.size()+1, maxFetchSize, done)
+		if err == nil {
+			continue
+		}
+		if factory.err == io.EOF {
+			return nil
+		}
+		if factory.err != nil {
+			return factory.err
+		}
+		if err == io.EOF {
+			// the factory has been closed before the source ended
+			return ErrFactoryClosed
+		}
+		return err
+	}
 }
 
-// GetFactory returns the DoppelgangerFactory if the reader is a Doppelganger
-func GetFactory(reader io.Reader) DoppelgangerFactory {
-	if v, ok := reader.(*readerInstance); ok {
-		return v.DoppelBase
+// Reset closes all active doppelgangers, ends the subscriptions, discards the buffer and uses r as the new source,
+// afterwards the factory behaves as if it had been created with r (the options are kept).
+// Doppelgangers that are waiting for the source fail with ErrFactoryReset.
+// The old source will not be closed. It returns ErrFactoryClosed if the factory has been closed.
+func (factory *doppelgangerFactory) Reset(r io.Reader) error {
+	var owned io.Closer
+	defer func() {
+		// the doppelganger of Copy belongs to another factory, close it without holding our lock
+		if owned != nil {
+			_ = owned.Close()
+		}
+	}()
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.closed {
+		return ErrFactoryClosed
+	}
+	if factory.isFrozen() {
+		return ErrFactoryFrozen
+	}
+	for _, reader := range factory.readers {
+		reader.closed = true
+		reader.end()
+		if reader.deadline != nil {
+			reader.deadline.timer.Stop()
+		}
+	}
+	factory.closedReaders += len(factory.readers)
+	factory.readers = nil
+	if factory.pins == 0 {
+		// release the chunks to the pool
+		factory.buffer.evict(factory.size())
+	}
+	factory.buffer = chunkedBuffer{chunkSize: factory.buffer.chunkSize}
+	factory.prefixSize = 0
+	factory.trimWatermark = 0
+
+	factory.switchSource(decompress(r, factory.config.decompression))
+	owned, factory.ownedSource = factory.ownedSource, nil
+	if factory.config.hasher != nil {
+		factory.config.hasher.Reset()
 	}
+	factory.tees = factory.config.tees
+	factory.teeErr = nil
+	factory.lastProgress = time.Time{}
+	factory.endSubscriptions()
+	factory.broadcast()
 	return nil
 }
 
-type nestedDoppelgangerFactory struct {
-	parent  DoppelgangerFactory
-	readers []io.ReadCloser
+// switchSource lets the factory read from source, pending reads on the old source will be ignored.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) switchSource(source io.Reader) {
+	factory.generation++
+	factory.source = source
+	factory.limitSource()
+	factory.err = nil
+	// a pending read on the old source keeps using the old scratch buffer
+	factory.fetching = false
+	factory.prefetching = false
+	factory.scratch = nil
 }
 
-func (factory *nestedDoppelgangerFactory) NewDoppelganger() io.ReadCloser {
-	r := factory.parent.NewDoppelganger()
-	factory.readers = append(factory.readers, r)
-	return r
+// Freeze marks the buffer of the factory as immutable, afterwards the doppelgangers read the buffer
+// without blocking each other (a single doppelganger must not be read by multiple goroutines at the same time).
+// It returns ErrSourceNotEOF if the source has not been read until io.EOF
+// (see Drain) and ErrFactoryClosed if the factory has been closed.
+// A frozen factory cannot be reset, it can be closed as usual.
+func (factory *doppelgangerFactory) Freeze() error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.closed {
+		return ErrFactoryClosed
+	}
+	if factory.err != io.EOF {
+		return ErrSourceNotEOF
+	}
+	atomic.StoreInt32(&factory.frozen, 1)
+	return nil
 }
 
-func (factory *nestedDoppelgangerFactory) RemoveDoppelganger(r io.ReadCloser) error {
-	return factory.parent.RemoveDoppelganger(r)
+func (factory *doppelgangerFactory) isFrozen() bool {
+	return atomic.LoadInt32(&factory.frozen) == 1
 }
 
-func (factory *nestedDoppelgangerFactory) Close() error {
-	for i := len(factory.readers) - 1; i >= 0; i-- {
-		if err := factory.RemoveDoppelganger(factory.readers[i]); err != nil {
-			return err
+// Close the DoppelgangerFactory and stops all created Doppelgangers from receiving data
+// (does not close the underlying reader, unless WithCloseSource is used)
+func (factory *doppelgangerFactory) Close() error {
+	// this is a public function so make sure we lock
+	factory.mu.Lock()
+	// capture the source closer under the lock, factory.source must not be read once it is released
+	var sourceCloser io.Closer
+	if closer, ok := factory.source.(io.Closer); ok && !factory.closed && factory.config.closeSource {
+		sourceCloser = closer
+	}
+	err := factory.close()
+	owned := factory.ownedSource
+	factory.ownedSource = nil
+	factory.mu.Unlock()
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if owned != nil {
+		if err := owned.Close(); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	factory.readers = nil
+	// the source of Copy has already been closed
+	if sourceCloser != nil && sourceCloser != owned {
+		if err := sourceCloser.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return combineErrors(errs)
+}
+
+// MustClose closes the DoppelgangerFactory like Close, but panics if Close returns an error.
+// It is meant for tests and cases where a failing close is unrecoverable.
+func (factory *doppelgangerFactory) MustClose() {
+	mustClose(factory)
+}
+
+// CloseAndLog closes the DoppelgangerFactory like Close and logs the error as warning if Close returns one,
+// unlike `defer factory.Close()` it does not drop the error silently.
+func (factory *doppelgangerFactory) CloseAndLog(logger Logger) {
+	closeAndLog(factory, logger)
+}
+
+func mustClose(factory DoppelgangerFactory) {
+	if err := factory.Close(); err != nil {
+		panic(err)
+	}
+}
+
+func closeAndLog(factory DoppelgangerFactory, logger Logger) {
+	if err := factory.Close(); err != nil {
+		logger.Printf("warning: doppelgangerreader: close failed: %v", err)
+	}
+}
+
+// close the DoppelgangerFactory and stops all created Doppelgangers from receiving data
+// (does not close the underlying reader)
+func (factory *doppelgangerFactory) close() error {
+	// we already closed
+	if factory.closed {
+		return nil
+	}
+	factory.closed = true
+	if factory.closedCh != nil {
+		close(factory.closedCh)
+	}
+
+	// remove all readers because everything has been consumed
+	for _, reader := range factory.readers {
+		reader.end()
+	}
+	factory.readers = nil
+	if !factory.fetching {
+		// a pending fetch ends the subscriptions once it is done
+		factory.endSubscriptions()
+	}
+	factory.broadcast()
+	return nil
+}
+
+// broadcast wakes up everyone that is waiting for a state change.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) broadcast() {
+	if factory.notify != nil {
+		close(factory.notify)
+		factory.notify = nil
+	}
+}
+
+// errWaitCanceled will be reported by fillBuffer if done was closed while waiting for the source
+var errWaitCanceled = errors.New("wait canceled")
+
+// wait blocks until the state of the factory changes or done is closed.
+// factory.mu must be held, it will be released while waiting.
+func (factory *doppelgangerFactory) wait(done <-chan struct{}) bool {
+	if factory.notify == nil {
+		factory.notify = make(chan struct{})
+	}
+	notify := factory.notify
+	factory.mu.Unlock()
+	defer factory.mu.Lock()
+	select {
+	case <-notify:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// fillBuffer makes sure that the buffer holds at least size bytes by reading from the source,
+// every read on the source requests at least n bytes (exactly the chunk size if WithChunkSize is used).
+// The source is read in the background, errWaitCanceled is returned as soon as done is closed
+// and ErrFactoryReset if the factory is reset while waiting.
+// factory.mu must be held, it will be released while reading from the source.
+func (factory *doppelgangerFactory) fillBuffer(size int64, n int, done <-chan struct{}) error {
+	generation := factory.generation
+	for factory.size() < size {
+		if generation != factory.generation {
+			return ErrFactoryReset
+		}
+		if factory.closed {
+			return io.EOF
+		}
+		if factory.err != nil {
+			return factory.err
+		}
+		if factory.source == nil {
+			return NilReaderError{}
+		}
+		if factory.fetching {
+			// someone else is already reading from the source, wait for the result
+			if !factory.wait(done) {
+				return errWaitCanceled
+			}
+			continue
+		}
+		need := size - factory.size()
+		if factory.config.chunkSize > 0 {
+			n = factory.config.chunkSize
+		} else if int64(n) < need {
+			n = maxFetchSize
+			if need < int64(n) {
+				n = int(need)
+			}
+		}
+		if factory.config.maxBufferSize > 0 {
+			free := factory.config.maxBufferSize - factory.bufferSize()
+			if free <= 0 {
+				// a WithNoLock factory has no other goroutine that could catch up
+				if factory.config.bufferFullBehavior == ErrorOnFull || factory.mu.noLock {
+					return ErrBufferFull
+				}
+				// wait for the slower readers to catch up
+				if !factory.wait(done) {
+					return errWaitCanceled
+				}
+				continue
+			}
+			if int64(n) > free {
+				n = int(free)
+			}
+		}
+		if factory.config.bufferLimit > 0 {
+			free := factory.config.bufferLimit - factory.buffer.length
+			if free <= 0 {
+				if factory.config.bufferLimitBehavior == ErrorOnExceed || factory.mu.noLock {
+					return ErrBufferLimitExceeded
+				}
+				// wait until buffered data has been evicted
+				if !factory.wait(done) {
+					return errWaitCanceled
+				}
+				continue
+			}
+			if int64(n) > free {
+				n = int(free)
+			}
+		}
+		if factory.mu.noLock {
+			// a WithNoLock factory must not be used by multiple goroutines, read in the current one
+			notify := factory.fetch(n)
+			if notify != nil {
+				factory.mu.Unlock()
+				notify()
+				factory.mu.Lock()
+			}
+			continue
+		}
+		// read in the background, so we can stop waiting when done is closed or the factory is reset
+		factory.fetching = true
+		go func(n int, generation int) {
+			factory.mu.Lock()
+			var notify func()
+			if generation == factory.generation {
+				notify = factory.fetch(n)
+			}
+			factory.mu.Unlock()
+			if notify != nil {
+				notify()
+			}
+		}(n, factory.generation)
+	}
+	return nil
+}
+
+// bufferSize returns the number of buffered bytes that have not been read by the slowest active reader.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) bufferSize() int64 {
+	return factory.size() - factory.watermark()
+}
+
+// watermark returns the position of the slowest active reader.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) watermark() int64 {
+	pos := factory.size()
+	for _, reader := range factory.readers {
+		if reader.pos < pos {
+			pos = reader.pos
+		}
+	}
+	return pos
+}
+
+// start returns the position of the oldest data that is still available,
+// data before it has been evicted or trimmed.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) start() int64 {
+	if factory.trimWatermark > factory.buffer.base {
+		return factory.trimWatermark
+	}
+	return factory.buffer.base
+}
+
+// size returns the number of bytes that have been read from the source.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) size() int64 {
+	return factory.buffer.size()
+}
+
+// moved must be called after a reader changed its position.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) moved() {
+	if factory.config.bufferEviction {
+		factory.evict()
+	} else {
+		factory.trim()
+	}
+	if factory.config.maxBufferSize > 0 || factory.config.bufferLimit > 0 || factory.waiters > 0 {
+		// faster readers or WaitAll might wait for us
+		factory.broadcast()
+	}
+}
+
+// evict releases the buffered data that has been read by all active readers.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) evict() {
+	// readers keep reading the buffered data after the factory was closed
+	// and we cannot evict while someone uses the buffer without holding the lock
+	if factory.closed || factory.pins > 0 {
+		return
+	}
+	factory.buffer.evict(factory.watermark())
+}
+
+// maxFetchSize is the maximum number of bytes that will be requested from the source
+// when the buffer needs to be filled up to a specific size.
+const maxFetchSize = 32 * 1024
+
+// fetch reads up to n bytes from the source and appends them to the buffer.
+// If the source ended it returns the WithOnSourceEOF (or WithOnSourceError) callback,
+// which must be called after factory.mu has been released.
+// factory.mu must be held, it will be released while reading from the source.
+func (factory *doppelgangerFactory) fetch(n int) func() {
+	factory.fetching = true
+	if cap(factory.scratch) < n {
+		factory.scratch = make([]byte, n)
+	}
+	p := factory.scratch[:n]
+	source := factory.source
+	generation := factory.generation
+	delay := factory.throttle(&p)
+	closedCh := factory.closedChan()
+
+	factory.mu.Unlock()
+	var err error
+	n = 0
+	if factory.sleep(delay, closedCh) {
+		n, err = source.Read(p)
+	}
+	factory.mu.Lock()
+	if factory.config.readRate > 0 {
+		factory.rateTokens -= float64(n)
+	}
+
+	if generation != factory.generation {
+		// the factory has been reset while we were reading, the data belongs to the old source
+		return nil
+	}
+	if n > 0 {
+		factory.buffer.write(p[:n])
+		if factory.config.hasher != nil {
+			_, _ = factory.config.hasher.Write(p[:n])
+		}
+		factory.tee(p[:n])
+	}
+	if err == io.EOF {
+		factory.err = err
+	} else if err != nil {
+		factory.err = &SourceError{Err: err}
+	}
+	if n > 0 || err != nil {
+		factory.progress()
+	}
+	if n > 0 && len(factory.subscribers) > 0 {
+		// let the readers continue while we push the chunk
+		factory.broadcast()
+		factory.publish(p[:n], closedCh)
+		if generation != factory.generation {
+			return nil
+		}
+	}
+	if err != nil || factory.closed {
+		factory.endSubscriptions()
+	}
+	factory.fetching = false
+	factory.broadcast()
+	if err == nil {
+		return nil
+	}
+	if err == io.EOF {
+		if fn := factory.config.onSourceEOF; fn != nil {
+			total := factory.size() - factory.prefixSize
+			return func() { fn(total) }
+		}
+		return nil
+	}
+	if fn := factory.config.onSourceError; fn != nil {
+		return func() { fn(err) }
+	}
+	return nil
+}
+
+// throttle limits p to the burst size of WithReadRate and returns how long to wait before reading from the source.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) throttle(p *[]byte) time.Duration {
+	rate := float64(factory.config.readRate)
+	if rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if factory.rateLast.IsZero() {
+		// start with a full bucket
+		factory.rateTokens = rate
+	} else {
+		factory.rateTokens += now.Sub(factory.rateLast).Seconds() * rate
+		if factory.rateTokens > rate {
+			factory.rateTokens = rate
+		}
+	}
+	factory.rateLast = now
+	if int64(len(*p)) > factory.config.readRate {
+		*p = (*p)[:factory.config.readRate]
+	}
+	if factory.rateTokens >= 0 {
+		return 0
+	}
+	return time.Duration(-factory.rateTokens / rate * float64(time.Second))
+}
+
+// sleep waits for delay, it returns false if the factory has been closed in the meantime
+func (factory *doppelgangerFactory) sleep(delay time.Duration, closedCh <-chan struct{}) bool {
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closedCh:
+		return false
+	}
+}
+
+// closedChan returns a channel that will be closed when the factory is closed.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) closedChan() <-chan struct{} {
+	if factory.closedCh == nil {
+		factory.closedCh = make(chan struct{})
+		if factory.closed {
+			close(factory.closedCh)
+		}
+	}
+	return factory.closedCh
+}
+
+// progress calls the function of WithProgressFunc, unless it has been called less than
+// the interval of WithProgressInterval ago. The end of the source is always reported.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) progress() {
+	if factory.config.progressFunc == nil {
+		return
+	}
+	now := time.Now()
+	if factory.err == nil && now.Sub(factory.lastProgress) < factory.config.progressInterval {
+		return
+	}
+	factory.lastProgress = now
+	factory.config.progressFunc(factory.size())
+}
+
+// tee writes p to the writers of WithTee, a writer that fails will not receive any further data.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) tee(p []byte) {
+	tees := factory.tees[:0]
+	for _, w := range factory.tees {
+		n, err := w.Write(p)
+		if err == nil && n != len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			if factory.teeErr == nil {
+				factory.teeErr = err
+			}
+			continue
+		}
+		tees = append(tees, w)
+	}
+	factory.tees = tees
+}
+
+// TeeError returns the first error a writer of WithTee reported, reads are not affected by these errors
+func (factory *doppelgangerFactory) TeeError() error {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	return factory.teeErr
+}
+
+type readerInstance struct {
+	DoppelBase *doppelgangerFactory
+	// ctx is used to stop waiting for the source, can be nil
+	ctx context.Context
+	// pos is the position of the reader in the buffer of the factory
+	pos    int64
+	closed bool
+	// deadline is set by SetReadDeadline, can be nil
+	deadline *readDeadline
+	// unreadByte is set if the last operation was a successful ReadByte
+	unreadByte bool
+	// lastRuneSize is the size of the rune of the last operation if it was a successful ReadRune, 0 otherwise
+	lastRuneSize int
+	// name is set by NewNamedDoppelganger
+	name string
+	// bytesDelivered and readCalls are updated atomically, see Stats
+	bytesDelivered int64
+	readCalls      int64
+	// endCh is closed once the reader will not receive new data, see ended
+	endCh chan struct{}
+	// readAhead is the minimum number of bytes a read on the source requests, see NewBufferedDoppelganger
+	readAhead int
+}
+
+func (r *readerInstance) Read(p []byte) (int, error) {
+	factory := r.DoppelBase
+	atomic.AddInt64(&r.readCalls, 1)
+	if factory.isFrozen() {
+		factory.mu.RLock()
+		// WaitAll and read deadlines need the full lock
+		if factory.waiters == 0 && r.deadline == nil {
+			n, err := r.readFrozen(p)
+			factory.mu.RUnlock()
+			return n, err
+		}
+		factory.mu.RUnlock()
+	}
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	r.forgetUnread()
+	if r.closed {
+		return 0, ErrDoppelgangerClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := r.fillBuffer(r.pos+1, len(p)); err != nil {
+		return 0, err
+	}
+	// the reader could have been closed while we were waiting for the source
+	if r.closed {
+		return 0, ErrDoppelgangerClosed
+	}
+	n := factory.buffer.copyAt(p, r.pos)
+	r.pos += int64(n)
+	atomic.AddInt64(&r.bytesDelivered, int64(n))
+	factory.moved()
+	return n, nil
+}
+
+// readFrozen reads from the buffer of a frozen factory, the buffer does not change anymore so the
+// readers do not need to block each other. Skipping moved only delays the eviction.
+// factory.mu must be held for reading.
+func (r *readerInstance) readFrozen(p []byte) (int, error) {
+	r.forgetUnread()
+	if r.closed {
+		return 0, ErrDoppelgangerClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.pos >= r.DoppelBase.size() {
+		return 0, io.EOF
+	}
+	n := r.DoppelBase.buffer.copyAt(p, r.pos)
+	r.pos += int64(n)
+	atomic.AddInt64(&r.bytesDelivered, int64(n))
+	return n, nil
+}
+
+// WriteTo writes the remaining data to w, it implements the io.WriterTo interface.
+// The position of the reader is advanced like it would with Read.
+func (r *readerInstance) WriteTo(w io.Writer) (int64, error) {
+	factory := r.DoppelBase
+	atomic.AddInt64(&r.readCalls, 1)
+	var total int64
+	for {
+		factory.mu.Lock()
+		r.forgetUnread()
+		if r.closed {
+			factory.mu.Unlock()
+			return total, ErrDoppelgangerClosed
+		}
+		if err := r.fillBuffer(r.pos+1, maxFetchSize); err != nil {
+			factory.mu.Unlock()
+			if err == io.EOF {
+				return total, nil
+			}
+			return total, err
+		}
+		// buffered data will never be modified, so we can write it without holding the lock
+		// as long as it is not evicted
+		p := factory.buffer.slice(r.pos)
+		factory.pins++
+		factory.mu.Unlock()
+
+		n, err := w.Write(p)
+
+		factory.mu.Lock()
+		factory.pins--
+		r.pos += int64(n)
+		atomic.AddInt64(&r.bytesDelivered, int64(n))
+		factory.moved()
+		factory.mu.Unlock()
+		total += int64(n)
+
+		if err != nil {
+			return total, err
+		}
+		if n != len(p) {
+			return total, io.ErrShortWrite
+		}
+	}
+}
+
+// ReadByte reads a single byte, it implements the io.ByteReader interface
+func (r *readerInstance) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	r.DoppelBase.mu.Lock()
+	r.unreadByte = true
+	r.DoppelBase.mu.Unlock()
+	return b[0], nil
+}
+
+// ReadRune reads a single UTF-8 encoded character, it implements the io.RuneReader interface.
+// Invalid encodings are reported as utf8.RuneError with a size of 1.
+func (r *readerInstance) ReadRune() (rune, int, error) {
+	factory := r.DoppelBase
+	atomic.AddInt64(&r.readCalls, 1)
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	r.forgetUnread()
+	if r.closed {
+		return 0, 0, ErrDoppelgangerClosed
+	}
+	if err := r.fillBuffer(r.pos+1, utf8.UTFMax); err != nil {
+		return 0, 0, err
+	}
+	var p [utf8.UTFMax]byte
+	n := factory.buffer.copyAt(p[:], r.pos)
+	if p[0] >= utf8.RuneSelf && !utf8.FullRune(p[:n]) {
+		// the character might continue in data that has not been read from the source yet
+		err := r.fillBuffer(r.pos+utf8.UTFMax, utf8.UTFMax)
+		n = factory.buffer.copyAt(p[:], r.pos)
+		if err != nil && err != io.EOF && err != factory.err && !utf8.FullRune(p[:n]) {
+			return 0, 0, err
+		}
+	}
+	if r.closed {
+		return 0, 0, ErrDoppelgangerClosed
+	}
+	c, size := utf8.DecodeRune(p[:n])
+	r.pos += int64(size)
+	atomic.AddInt64(&r.bytesDelivered, int64(size))
+	r.lastRuneSize = size
+	factory.moved()
+	return c, size, nil
+}
+
+// UnreadRune moves the reader back by the size of the last rune, it implements the io.RuneScanner interface.
+// Only the rune of the last successful ReadRune can be unread, otherwise bufio.ErrInvalidUnreadRune is returned.
+func (r *readerInstance) UnreadRune() error {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return ErrDoppelgangerClosed
+	}
+	if r.lastRuneSize <= 0 {
+		return bufio.ErrInvalidUnreadRune
+	}
+	if r.pos-int64(r.lastRuneSize) < factory.start() {
+		return ErrEvicted
+	}
+	r.pos -= int64(r.lastRuneSize)
+	r.forgetUnread()
+	return nil
+}
+
+// forgetUnread makes the last operation impossible to undo with UnreadByte or UnreadRune.
+func (r *readerInstance) forgetUnread() {
+	r.unreadByte = false
+	r.lastRuneSize = 0
+}
+
+// ReadBytes reads until the first occurrence of delim and returns the data including delim,
+// the data will be read from the source if necessary. Like bufio.Reader.ReadBytes it returns
+// an error (io.EOF or the error of the source) if and only if the data does not end in delim.
+func (r *readerInstance) ReadBytes(delim byte) ([]byte, error) {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	r.forgetUnread()
+	atomic.AddInt64(&r.readCalls, 1)
+	var line []byte
+	defer func() {
+		atomic.AddInt64(&r.bytesDelivered, int64(len(line)))
+	}()
+	for {
+		if r.closed {
+			return line, ErrDoppelgangerClosed
+		}
+		if err := r.fillBuffer(r.pos+1, 0); err != nil {
+			return line, err
+		}
+		if r.closed {
+			return line, ErrDoppelgangerClosed
+		}
+		p := factory.buffer.slice(r.pos)
+		if i := bytes.IndexByte(p, delim); i >= 0 {
+			line = append(line, p[:i+1]...)
+			r.pos += int64(i + 1)
+			r.unreadByte = true
+			factory.moved()
+			return line, nil
+		}
+		// move along with the buffer, so this reader does not hold back the buffer size limit
+		line = append(line, p...)
+		r.pos += int64(len(p))
+		factory.moved()
+	}
+}
+
+// ReadString works like ReadBytes, but returns a string
+func (r *readerInstance) ReadString(delim byte) (string, error) {
+	line, err := r.ReadBytes(delim)
+	return string(line), err
+}
+
+// UnreadByte moves the reader back by one byte, it implements the io.ByteScanner interface.
+// Only the byte of the last successful ReadByte can be unread, otherwise bufio.ErrInvalidUnreadByte is returned.
+func (r *readerInstance) UnreadByte() error {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return ErrDoppelgangerClosed
+	}
+	if !r.unreadByte {
+		return bufio.ErrInvalidUnreadByte
+	}
+	if r.pos-1 < factory.start() {
+		return ErrEvicted
+	}
+	r.forgetUnread()
+	r.pos--
 	return nil
 }
 
+// fillBuffer fills the buffer of the factory, see doppelgangerFactory.fillBuffer
+// it stops waiting for the source if the context of the reader is done or the read deadline passed.
+func (r *readerInstance) fillBuffer(size int64, n int) error {
+	if n < r.readAhead {
+		n = r.readAhead
+	}
+	for {
+		if r.deadline != nil && r.deadline.exceeded() {
+			return DeadlineExceeded{}
+		}
+		done, stop := r.done()
+		err := r.DoppelBase.fillBuffer(size, n, done)
+		stop()
+		if err != errWaitCanceled {
+			return err
+		}
+		if r.ctx != nil && r.ctx.Err() != nil {
+			return r.ctx.Err()
+		}
+		// the deadline passed or has been changed, check again
+	}
+}
+
+// done returns a channel that will be closed if the context of the reader is done
+// or the read deadline passed, stop must be called once the channel is not needed anymore.
+// r.DoppelBase.mu must be held.
+func (r *readerInstance) done() (<-chan struct{}, func()) {
+	if r.deadline == nil {
+		if r.ctx == nil {
+			return nil, func() {}
+		}
+		return r.ctx.Done(), func() {}
+	}
+	if r.ctx == nil {
+		return r.deadline.ch, func() {}
+	}
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	go func(ctxDone <-chan struct{}, deadline <-chan struct{}) {
+		select {
+		case <-ctxDone:
+		case <-deadline:
+		case <-stop:
+			return
+		}
+		close(done)
+	}(r.ctx.Done(), r.deadline.ch)
+	return done, func() { close(stop) }
+}
+
+// SetReadDeadline sets the deadline for Read (and the other methods that wait for the source),
+// after the deadline passed they fail with DeadlineExceeded even if there is buffered data.
+// Calls that are already waiting are affected as well, a zero t disables the deadline.
+// The deadline only applies to this doppelganger.
+func (r *readerInstance) SetReadDeadline(t time.Time) error {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return ErrDoppelgangerClosed
+	}
+	if r.deadline != nil {
+		// wake up the calls waiting for the old deadline
+		r.deadline.cancel()
+		r.deadline = nil
+	}
+	if t.IsZero() {
+		return nil
+	}
+	d := &readDeadline{
+		t:  t,
+		ch: make(chan struct{}),
+	}
+	d.timer = time.AfterFunc(time.Until(t), func() {
+		factory.mu.Lock()
+		d.fired = true
+		d.cancel()
+		factory.mu.Unlock()
+	})
+	r.deadline = d
+	return nil
+}
+
+// readDeadline closes ch once t passed (or it has been replaced),
+// it is guarded by the mutex of the factory
+type readDeadline struct {
+	t      time.Time
+	ch     chan struct{}
+	timer  *time.Timer
+	closed bool
+	fired  bool
+}
+
+func (d *readDeadline) exceeded() bool {
+	return d.fired || !time.Now().Before(d.t)
+}
+
+func (d *readDeadline) cancel() {
+	d.timer.Stop()
+	if !d.closed {
+		d.closed = true
+		close(d.ch)
+	}
+}
+
+// Seek sets the position for the next Read, it implements the io.Seeker interface.
+// Seeking backwards only moves inside the already buffered data, seeking forward
+// reads from the source if necessary. io.SeekEnd reads the source until the end.
+// ErrSeekBeyondEnd is returned if the source ends before the position.
+func (r *readerInstance) Seek(offset int64, whence int) (int64, error) {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return 0, ErrDoppelgangerClosed
+	}
+	r.forgetUnread()
+
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		for {
+			err := r.fillBuffer(factory.size()+1, maxFetchSize)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return r.pos, err
+			}
+		}
+		pos = factory.size() + offset
+	default:
+		return r.pos, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return r.pos, errors.New("negative position")
+	}
+	if pos < factory.start() {
+		return r.pos, ErrEvicted
+	}
+
+	current := r.pos
+	for factory.size() < pos {
+		// move along with the buffer, so this reader does not hold back the buffer size limit
+		r.pos = factory.size()
+		n := pos - r.pos
+		if n > maxFetchSize {
+			n = maxFetchSize
+		}
+		if err := r.fillBuffer(r.pos+1, int(n)); err != nil {
+			r.pos = current
+			if err == io.EOF {
+				return r.pos, ErrSeekBeyondEnd
+			}
+			return r.pos, err
+		}
+	}
+	r.pos = pos
+	factory.moved()
+	return pos, nil
+}
+
+// Peek returns the next n bytes without advancing the reader, the data will be read from the source if necessary.
+// The returned slice is a copy. If the stream ends before n bytes are available
+// the available bytes are returned with io.EOF (or the error of the source).
+// Like with bufio.Reader, UnreadByte and UnreadRune fail after Peek.
+func (r *readerInstance) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("negative count")
+	}
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return nil, ErrDoppelgangerClosed
+	}
+	r.forgetUnread()
+	err := r.fillBuffer(r.pos+int64(n), n)
+	if r.closed {
+		return nil, ErrDoppelgangerClosed
+	}
+	available := factory.size() - r.pos
+	if available > int64(n) {
+		available = int64(n)
+	}
+	p := make([]byte, available)
+	factory.buffer.copyAt(p, r.pos)
+	if len(p) < n {
+		return p, err
+	}
+	return p, nil
+}
+
+// Discard skips the next n bytes, the data will be read from the source if necessary.
+// It returns the number of bytes skipped, if the stream ends before n bytes could be skipped
+// io.EOF (or the error of the source) is returned.
+func (r *readerInstance) Discard(n int64) (int64, error) {
+	if n < 0 {
+		return 0, errors.New("negative count")
+	}
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if r.closed {
+		return 0, ErrDoppelgangerClosed
+	}
+	r.forgetUnread()
+	start := r.pos
+	target := r.pos + n
+	for factory.size() < target {
+		// move along with the buffer, so this reader does not hold back the buffer size limit
+		r.pos = factory.size()
+		factory.moved()
+		need := target - r.pos
+		if need > maxFetchSize {
+			need = maxFetchSize
+		}
+		if err := r.fillBuffer(r.pos+1, int(need)); err != nil {
+			return r.pos - start, err
+		}
+		if r.closed {
+			return r.pos - start, ErrDoppelgangerClosed
+		}
+	}
+	r.pos = target
+	factory.moved()
+	return n, nil
+}
+
+// IsDoppelganger returns true, it implements Doppelganger
+func (r *readerInstance) IsDoppelganger() bool {
+	return true
+}
+
+// Name returns the name the reader has been created with, see NewNamedDoppelganger
+func (r *readerInstance) Name() string {
+	return r.name
+}
+
+// Position returns the number of bytes the reader delivered (or skipped by seeking)
+func (r *readerInstance) Position() int64 {
+	r.DoppelBase.mu.Lock()
+	defer r.DoppelBase.mu.Unlock()
+	return r.pos
+}
+
+// SeekRelative moves the position of the reader by delta bytes and returns the new position,
+// it is a shortcut for Seek(delta, io.SeekCurrent)
+func (r *readerInstance) SeekRelative(delta int64) (int64, error) {
+	return r.Seek(delta, io.SeekCurrent)
+}
+
+// Reset sets the position of the reader back to the beginning of the stream.
+// It returns ErrFactoryClosed if the factory has been closed.
+func (r *readerInstance) Reset() error {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.closed {
+		return ErrFactoryClosed
+	}
+	if r.closed {
+		return ErrDoppelgangerClosed
+	}
+	if factory.start() > 0 {
+		return ErrEvicted
+	}
+	r.forgetUnread()
+	r.pos = 0
+	return nil
+}
+
+// pending returns the number of buffered bytes the reader did not consume yet,
+// finished is true if the reader has been closed or no more data will arrive
+func (r *readerInstance) pending() (pending int64, finished bool) {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	pending = factory.size() - r.pos
+	if r.closed {
+		return 0, true
+	}
+	return pending, pending == 0 && (factory.closed || factory.err != nil)
+}
+
+// sourceErr returns the error the source reported, nil if the source did not fail (yet)
+func (r *readerInstance) sourceErr() error {
+	r.DoppelBase.mu.Lock()
+	defer r.DoppelBase.mu.Unlock()
+	return r.DoppelBase.err
+}
+
+// Rewind sets the position of the reader back to the beginning of the stream, see Reset.
+// The reader keeps its name and read deadline.
+func (r *readerInstance) Rewind() error {
+	return r.Reset()
+}
+
+// active returns true if the reader has not been closed and the factory is still open
+func (r *readerInstance) active() bool {
+	r.DoppelBase.mu.Lock()
+	defer r.DoppelBase.mu.Unlock()
+	return !r.closed && !r.DoppelBase.closed
+}
+
+// ended returns a channel that will be closed when the reader has been closed
+// or the factory has been closed or reset.
+// r.DoppelBase.mu must be held.
+func (r *readerInstance) ended() <-chan struct{} {
+	if r.endCh == nil {
+		r.endCh = make(chan struct{})
+		if r.closed || r.DoppelBase.closed {
+			close(r.endCh)
+		}
+	}
+	return r.endCh
+}
+
+// end closes the channel returned by ended.
+// r.DoppelBase.mu must be held.
+func (r *readerInstance) end() {
+	if r.endCh != nil {
+		select {
+		case <-r.endCh:
+		default:
+			close(r.endCh)
+		}
+	}
+}
+
+func (r *readerInstance) Close() error {
+	factory := r.DoppelBase
+	factory.mu.Lock()
+	// if the factory is already closed
+	// we dont need to remove
+	if factory.closed {
+		if !r.closed {
+			r.closed = true
+			r.end()
+			factory.closedReaders++
+		}
+		factory.broadcast()
+		factory.mu.Unlock()
+		return nil
+	}
+	factory.mu.Unlock()
+	return factory.RemoveDoppelganger(r)
+}
+
+// Positioner is implemented by doppelgangers, it reports the current read position in the stream
+type Positioner interface {
+	Position() int64
+}
+
+// wrappedReader is a doppelganger that has been decorated with additional behaviour,
+// Close will be passed to the underlying doppelganger
+type wrappedReader struct {
+	io.Reader
+	io.Closer
+}
+
+// ErrFactoryClosed will be reported if an operation requires an open factory
+var ErrFactoryClosed = errors.New("factory is closed")
+
+// ErrSourceNotEOF will be reported by Freeze if the source has not been read until io.EOF
+var ErrSourceNotEOF = errors.New("source has not reached EOF")
+
+// ErrFactoryFrozen will be reported by Reset if the factory has been frozen
+var ErrFactoryFrozen = errors.New("factory is frozen")
+
+// ErrFactoryReset will be reported to doppelgangers that were waiting for the source while the factory was reset
+var ErrFactoryReset = errors.New("factory has been reset")
+
+// ErrDoppelgangerBehindTrimPoint will be reported by Trim if an active doppelganger
+// did not read the data that should be released yet
+var ErrDoppelgangerBehindTrimPoint = errors.New("doppelganger is behind the trim point")
+
+// ErrEvicted will be reported if data is requested that has already been evicted from the buffer,
+// see WithBufferEviction
+var ErrEvicted = errors.New("data has been evicted from the buffer")
+
+// ErrDoppelgangerClosed will be reported by the doppelgangers that have been closed
+// (or removed from the factory), in contrast to io.EOF which reports the end of the stream
+var ErrDoppelgangerClosed = errors.New("doppelganger is closed")
+
+// IsClosedError returns true if the specified error is (or wraps) ErrDoppelgangerClosed
+func IsClosedError(err error) bool {
+	return errors.Is(err, ErrDoppelgangerClosed)
+}
+
+// ErrSeekBeyondEnd will be reported by Seek if the position is beyond the end of the source
+var ErrSeekBeyondEnd = errors.New("position is beyond the end of the source")
+
+// DeadlineExceeded will be reported if the deadline set with SetReadDeadline passed
+type DeadlineExceeded struct{}
+
+// Error returns the error message
+func (DeadlineExceeded) Error() string {
+	return "read deadline exceeded"
+}
+
+// Timeout returns true, so os.IsTimeout reports DeadlineExceeded as a timeout
+func (DeadlineExceeded) Timeout() bool {
+	return true
+}
+
+// CloseError will be reported if multiple close operations failed, Errs holds all of their errors
+type CloseError struct {
+	Errs []error
+}
+
+// Error returns the messages of all errors, separated by newlines
+func (e *CloseError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns all errors
+func (e *CloseError) Unwrap() []error {
+	return e.Errs
+}
+
+// Is returns true if one of the errors matches target, so errors.Is works before Go 1.20 as well
+func (e *CloseError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// combineErrors returns nil for no errors, the error itself for a single error and a CloseError otherwise.
+func combineErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &CloseError{Errs: errs}
+	}
+}
+
+// NilReaderError will be reported if the provided reader is nil
+type NilReaderError struct{}
+
+// Error returns the error message
+func (NilReaderError) Error() string {
+	return "Reader to mimic is nil"
+}
+
+// IsNilReaderError returns true if the specified error is (or wraps) a NilReaderError
+func IsNilReaderError(e error) bool {
+	return errors.As(e, &NilReaderError{})
+}
+
+// SourceError will be reported by the doppelgangers and the factory if the original reader failed
+// with an error other than io.EOF, Err is the error of the original reader
+type SourceError struct {
+	Err error
+}
+
+// Error returns the error message
+func (e *SourceError) Error() string {
+	return "doppelgangerreader: source read error: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the original reader
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// IsSourceError returns true if the specified error is (or wraps) a SourceError
+func IsSourceError(err error) bool {
+	var sourceErr *SourceError
+	return errors.As(err, &sourceErr)
+}
+
+// UnwrapSourceError returns the error of the original reader if the specified error is (or wraps) a SourceError,
+// otherwise err is returned unchanged
+func UnwrapSourceError(err error) error {
+	var sourceErr *SourceError
+	if errors.As(err, &sourceErr) {
+		return sourceErr.Err
+	}
+	return err
+}
+
+// GetFactory returns the DoppelgangerFactory if the reader is a Doppelganger
+func GetFactory(reader io.Reader) DoppelgangerFactory {
+	if v, ok := reader.(*readerInstance); ok {
+		return v.DoppelBase
+	}
+	return nil
+}
+
+// IsDoppelgangerOf returns true if the reader is a Doppelganger that has been created by the factory
+func IsDoppelgangerOf(reader io.Reader, factory DoppelgangerFactory) bool {
+	instance, ok := reader.(*readerInstance)
+	if !ok {
+		return false
+	}
+	switch f := factory.(type) {
+	case *doppelgangerFactory:
+		return instance.DoppelBase == f
+	case *multiFactory:
+		return instance.DoppelBase == f.doppelgangerFactory
+	case *nestedDoppelgangerFactory:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, r := range f.readers {
+			if r == reader {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type nestedDoppelgangerFactory struct {
+	parent      DoppelgangerFactory
+	readers     []io.ReadCloser
+	mu          sync.Mutex
+	asyncCloses pendingCloses
+}
+
+// track adds a reader to the readers of the nested factory
+func (factory *nestedDoppelgangerFactory) track(r io.ReadCloser) io.ReadCloser {
+	factory.mu.Lock()
+	factory.readers = append(factory.readers, r)
+	factory.mu.Unlock()
+	return r
+}
+
+// active returns the readers of the nested factory that have not been closed
+func (factory *nestedDoppelgangerFactory) active() []io.ReadCloser {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	var readers []io.ReadCloser
+	for _, r := range factory.readers {
+		if instance, ok := r.(*readerInstance); ok && instance.active() {
+			readers = append(readers, r)
+		}
+	}
+	return readers
+}
+
+func (factory *nestedDoppelgangerFactory) NewDoppelganger() io.ReadCloser {
+	return factory.track(factory.parent.NewDoppelganger())
+}
+
+func (factory *nestedDoppelgangerFactory) NewDoppelgangerErr() (io.ReadCloser, error) {
+	r, err := factory.parent.NewDoppelgangerErr()
+	if err != nil {
+		return nil, err
+	}
+	return factory.track(r), nil
+}
+
+func (factory *nestedDoppelgangerFactory) NewContextDoppelganger(ctx context.Context) io.ReadCloser {
+	return factory.track(factory.parent.NewContextDoppelganger(ctx))
+}
+
+func (factory *nestedDoppelgangerFactory) NewDoppelgangerAt(offset int64) (io.ReadCloser, error) {
+	r, err := factory.parent.NewDoppelgangerAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	return factory.track(r), nil
+}
+
+func (factory *nestedDoppelgangerFactory) NewAsyncCloseDoppelganger() io.ReadCloser {
+	return newAsyncCloseDoppelganger(factory, &factory.asyncCloses)
+}
+
+func (factory *nestedDoppelgangerFactory) WaitForAsyncCloses(ctx context.Context) error {
+	return waitForAsyncCloses(ctx, &factory.asyncCloses)
+}
+
+func (factory *nestedDoppelgangerFactory) Fork(startOffset int64) (DoppelgangerFactory, error) {
+	return factory.parent.Fork(startOffset)
+}
+
+func (factory *nestedDoppelgangerFactory) NewNamedDoppelganger(name string) (io.ReadCloser, error) {
+	r, err := factory.parent.NewNamedDoppelganger(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory.track(r), nil
+}
+
+func (factory *nestedDoppelgangerFactory) GetDoppelganger(name string) (io.ReadCloser, bool) {
+	return factory.parent.GetDoppelganger(name)
+}
+
+func (factory *nestedDoppelgangerFactory) RemoveDoppelgangerByName(name string) error {
+	return factory.parent.RemoveDoppelgangerByName(name)
+}
+
+func (factory *nestedDoppelgangerFactory) Reset(r io.Reader) error {
+	return errors.New("a nested factory can not be reset")
+}
+
+func (factory *nestedDoppelgangerFactory) WriteTo(w io.Writer) (int64, error) {
+	return factory.parent.WriteTo(w)
+}
+
+func (factory *nestedDoppelgangerFactory) Trim(upToOffset int64) error {
+	return factory.parent.Trim(upToOffset)
+}
+
+func (factory *nestedDoppelgangerFactory) TrimWatermark() int64 {
+	return factory.parent.TrimWatermark()
+}
+
+func (factory *nestedDoppelgangerFactory) Subscribe(ch chan<- []byte) (cancel func(), err error) {
+	return factory.parent.Subscribe(ch)
+}
+
+func (factory *nestedDoppelgangerFactory) Tail(n int64) (io.ReadCloser, error) {
+	r, err := factory.parent.Tail(n)
+	if err != nil {
+		return nil, err
+	}
+	return factory.track(r), nil
+}
+
+func (factory *nestedDoppelgangerFactory) Freeze() error {
+	return factory.parent.Freeze()
+}
+
+func (factory *nestedDoppelgangerFactory) NewBarrier(offset int64) *Barrier {
+	return factory.parent.NewBarrier(offset)
+}
+
+func (factory *nestedDoppelgangerFactory) Copy(dst DoppelgangerFactory) error {
+	return factory.parent.Copy(dst)
+}
+
+func (factory *nestedDoppelgangerFactory) SnapshotReader() io.Reader {
+	return factory.parent.SnapshotReader()
+}
+
+func (factory *nestedDoppelgangerFactory) Stats() FactoryStats {
+	return factory.parent.Stats()
+}
+
+func (factory *nestedDoppelgangerFactory) Remaining() (int64, bool) {
+	return factory.parent.Remaining()
+}
+
+func (factory *nestedDoppelgangerFactory) RemoveDoppelganger(r io.ReadCloser) error {
+	return factory.parent.RemoveDoppelganger(r)
+}
+
+func (factory *nestedDoppelgangerFactory) RemoveDoppelgangerReader(r io.Reader) error {
+	return factory.parent.RemoveDoppelgangerReader(r)
+}
+
+func (factory *nestedDoppelgangerFactory) ReadAt(p []byte, off int64) (int, error) {
+	return factory.parent.ReadAt(p, off)
+}
+
+func (factory *nestedDoppelgangerFactory) BufferSize() int64 {
+	return factory.parent.BufferSize()
+}
+
+func (factory *nestedDoppelgangerFactory) BufferedBytes() int64 {
+	return factory.parent.BufferedBytes()
+}
+
+func (factory *nestedDoppelgangerFactory) Bytes() []byte {
+	return factory.parent.Bytes()
+}
+
+func (factory *nestedDoppelgangerFactory) SourceEOF() bool {
+	return factory.parent.SourceEOF()
+}
+
+func (factory *nestedDoppelgangerFactory) Prefetch(ctx context.Context) error {
+	return factory.parent.Prefetch(ctx)
+}
+
+func (factory *nestedDoppelgangerFactory) TeeError() error {
+	return factory.parent.TeeError()
+}
+
+func (factory *nestedDoppelgangerFactory) SumHash(b []byte) []byte {
+	return factory.parent.SumHash(b)
+}
+
+func (factory *nestedDoppelgangerFactory) CloseAllDoppelgangers() error {
+	return closeAll(factory.active())
+}
+
+func (factory *nestedDoppelgangerFactory) ActiveDoppelgangerCount() int {
+	return len(factory.active())
+}
+
+func (factory *nestedDoppelgangerFactory) ListDoppelgangers() []io.ReadCloser {
+	return factory.active()
+}
+
+func (factory *nestedDoppelgangerFactory) WaitAll(ctx context.Context) error {
+	return factory.parent.WaitAll(ctx)
+}
+
+func (factory *nestedDoppelgangerFactory) Drain() error {
+	return factory.parent.Drain()
+}
+
+func (factory *nestedDoppelgangerFactory) Close() error {
+	readers := factory.active()
+	var errs []error
+	for i := len(readers) - 1; i >= 0; i-- {
+		if err := factory.RemoveDoppelganger(readers[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	factory.mu.Lock()
+	factory.readers = nil
+	factory.mu.Unlock()
+	return combineErrors(errs)
+}
+
+func (factory *nestedDoppelgangerFactory) MustClose() {
+	mustClose(factory)
+}
+
+func (factory *nestedDoppelgangerFactory) CloseAndLog(logger Logger) {
+	closeAndLog(factory, logger)
+}
+
 // HTTPMiddleware adds a doppelganger factory for the body to the request.
 // At the same time it replaces the original body with a doppelganger reader.
 // You can specify a size limit for the reader (0 disables the limit)
@@ -1,17 +1,25 @@
 package doppelgangerreader_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Eun/go-doppelgangerreader"
 )
@@ -81,8 +89,14 @@ func TestReaderInstanceClose(t *testing.T) {
 	}
 
 	_, err := reader1.Read(buf1)
-	if err != io.EOF {
-		t.Fatalf("expected io.EOF, but got %T", err)
+	if err != doppelgangerreader.ErrDoppelgangerClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDoppelgangerClosed, err)
+	}
+	if !doppelgangerreader.IsClosedError(err) {
+		t.Fatalf("expected %v to be a closed error", err)
+	}
+	if doppelgangerreader.IsClosedError(io.EOF) {
+		t.Fatalf("expected %v not to be a closed error", io.EOF)
 	}
 }
 
@@ -189,6 +203,55 @@ func TestDoppelganger_RemoveReader(t *testing.T) {
 			t.Fatalf("expected error")
 		}
 	})
+
+	t.Run("plain reader", func(t *testing.T) {
+		reader := doppelgangerreader.NewFactory(rand.Reader)
+		defer reader.Close()
+
+		var r1 io.Reader = reader.NewDoppelganger()
+		if err := reader.RemoveDoppelgangerReader(r1); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n := reader.ActiveDoppelgangerCount(); n != 0 {
+			t.Fatalf("expected %d, but got %d", 0, n)
+		}
+
+		invalid := bytes.NewBuffer(nil)
+		expected := reader.RemoveDoppelganger(ioutil.NopCloser(invalid))
+		if err := reader.RemoveDoppelgangerReader(invalid); err == nil || err.Error() != expected.Error() {
+			t.Fatalf("expected %v, but got %v", expected, err)
+		}
+	})
+}
+
+func TestIsDoppelgangerOf(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+	other := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer other.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+
+	if !doppelgangerreader.IsDoppelgangerOf(reader, factory) {
+		t.Fatal("expected reader to be a doppelganger of factory")
+	}
+	if doppelgangerreader.IsDoppelgangerOf(reader, other) {
+		t.Fatal("expected reader not to be a doppelganger of other")
+	}
+	if doppelgangerreader.IsDoppelgangerOf(bytes.NewBuffer(nil), factory) {
+		t.Fatal("expected buffer not to be a doppelganger of factory")
+	}
+
+	nested := doppelgangerreader.NewFactory(reader)
+	nestedReader := nested.NewDoppelganger()
+	defer nestedReader.Close()
+	if !doppelgangerreader.IsDoppelgangerOf(nestedReader, nested) {
+		t.Fatal("expected nestedReader to be a doppelganger of nested")
+	}
+	if doppelgangerreader.IsDoppelgangerOf(reader, nested) {
+		t.Fatal("expected reader not to be a doppelganger of nested")
+	}
 }
 
 func TestConcurrent(t *testing.T) {
@@ -316,6 +379,40 @@ func TestFillBufferEOFOnFirstCall(t *testing.T) {
 	}
 }
 
+type partialErrorReader struct {
+	err error
+}
+
+func (r partialErrorReader) Read(p []byte) (int, error) {
+	return copy(p, []byte{1, 2, 3}), r.err
+}
+
+func TestFillBufferErrorOnFirstCall(t *testing.T) {
+	networkErr := errors.New("network reset")
+	factory := doppelgangerreader.NewFactory(partialErrorReader{err: networkErr})
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	defer reader1.Close()
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+
+	for _, reader := range []io.Reader{reader1, reader2} {
+		buf := make([]byte, 10)
+		n, err := reader.Read(buf)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal([]byte{1, 2, 3}, buf[:n]) {
+			t.Fatalf("expected %v, but got %v", []byte{1, 2, 3}, buf[:n])
+		}
+		// the error is reported on the subsequent read
+		if n, err = reader.Read(buf); n != 0 || !errors.Is(err, networkErr) {
+			t.Fatalf("expected %v, but got %d, %v", networkErr, n, err)
+		}
+	}
+}
+
 func TestHttpMultipartReader(t *testing.T) {
 	// parts from mime/multipart/writer_test.go (go1.12.5)
 	fileContents := []byte("my file contents")
@@ -556,3 +653,1858 @@ func TestHTTPMiddleware(t *testing.T) {
 		t.Fatalf("expected %v, but got %v", []byte{'O', 'K'}, body)
 	}
 }
+
+func TestSeek(t *testing.T) {
+	payload := []byte("Hello World")
+
+	t.Run("backwards", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		reader := factory.NewDoppelganger().(io.ReadSeeker)
+		buf1 := readAtLeast(t, reader, 5)
+
+		pos, err := reader.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if pos != 0 {
+			t.Fatalf("expected 0, but got %d", pos)
+		}
+		buf2 := readAtLeast(t, reader, 5)
+		if !bytes.Equal(buf1, buf2) {
+			t.Fatalf("expected %v, but got %v", buf1, buf2)
+		}
+
+		pos, err = reader.Seek(-2, io.SeekCurrent)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if pos != 3 {
+			t.Fatalf("expected 3, but got %d", pos)
+		}
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload[3:], buf) {
+			t.Fatalf("expected %v, but got %v", payload[3:], buf)
+		}
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		reader := factory.NewDoppelganger().(io.ReadSeeker)
+		pos, err := reader.Seek(6, io.SeekStart)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if pos != 6 {
+			t.Fatalf("expected 6, but got %d", pos)
+		}
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload[6:], buf) {
+			t.Fatalf("expected %v, but got %v", payload[6:], buf)
+		}
+
+		// other readers still see everything
+		buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	t.Run("end", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		reader := factory.NewDoppelganger().(io.ReadSeeker)
+		pos, err := reader.Seek(-5, io.SeekEnd)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if pos != 6 {
+			t.Fatalf("expected 6, but got %d", pos)
+		}
+	})
+
+	t.Run("beyond end", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		reader := factory.NewDoppelganger().(io.ReadSeeker)
+		if _, err := reader.Seek(int64(len(payload)+1), io.SeekStart); !errors.Is(err, doppelgangerreader.ErrSeekBeyondEnd) {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrSeekBeyondEnd, err)
+		}
+		if _, err := reader.Seek(-1, io.SeekStart); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestReadAt(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	buf1 := readAtLeast(t, reader, 2)
+
+	// read data that has not been buffered yet
+	buf := make([]byte, 5)
+	n, err := factory.ReadAt(buf, 6)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[6:], buf[:n]) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf[:n])
+	}
+
+	// read data that has already been consumed
+	n, err = factory.ReadAt(buf[:2], 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(buf1, buf[:n]) {
+		t.Fatalf("expected %v, but got %v", buf1, buf[:n])
+	}
+
+	// the reader position must not be changed
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[2:], rest) {
+		t.Fatalf("expected %v, but got %v", payload[2:], rest)
+	}
+
+	// read beyond the end
+	n, err = factory.ReadAt(buf, 8)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+	if !bytes.Equal(payload[8:], buf[:n]) {
+		t.Fatalf("expected %v, but got %v", payload[8:], buf[:n])
+	}
+}
+
+func TestReadAtContract(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 1000)
+	factory := doppelgangerreader.NewFactory(iotest.HalfReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	// concurrent reads at different offsets
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(off int64) {
+			defer wg.Done()
+			buf := make([]byte, 100)
+			n, err := factory.ReadAt(buf, off)
+			if err != nil {
+				t.Errorf("expected no error, but got %v", err)
+				return
+			}
+			if !bytes.Equal(payload[off:off+100], buf[:n]) {
+				t.Errorf("expected %v, but got %v", payload[off:off+100], buf[:n])
+			}
+		}(int64(i * 1000))
+	}
+	wg.Wait()
+
+	size := int64(len(payload))
+	// a read that ends exactly at the end of the stream
+	buf := make([]byte, 11)
+	if n, err := factory.ReadAt(buf, size-11); n != 11 || (err != nil && err != io.EOF) {
+		t.Fatalf("expected 11 bytes, but got %d, %v", n, err)
+	}
+	// reads at or beyond the end
+	for _, off := range []int64{size, size + 10} {
+		for _, p := range [][]byte{buf, nil} {
+			if n, err := factory.ReadAt(p, off); n != 0 || err != io.EOF {
+				t.Fatalf("expected 0, io.EOF, but got %d, %v", n, err)
+			}
+		}
+	}
+	// an empty read inside the stream
+	if n, err := factory.ReadAt(nil, 5); n != 0 || err != nil {
+		t.Fatalf("expected 0, nil, but got %d, %v", n, err)
+	}
+
+	// errors of the source are reported
+	sourceErr := errors.New("connection reset")
+	factory = doppelgangerreader.NewFactory(io.MultiReader(bytes.NewReader(payload[:10]), &errorReader{sourceErr}))
+	defer factory.Close()
+	if n, err := factory.ReadAt(buf, 5); n != 5 || !errors.Is(err, sourceErr) {
+		t.Fatalf("expected 5, %v, but got %d, %v", sourceErr, n, err)
+	}
+}
+
+type blockingReader struct {
+	data    []byte
+	release chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.release
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestNewContextDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	source := &blockingReader{
+		data:    payload,
+		release: make(chan struct{}),
+	}
+	factory := doppelgangerreader.NewFactory(source)
+	defer factory.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := factory.NewContextDoppelganger(ctx)
+	defer reader.Close()
+
+	result := make(chan error)
+	go func() {
+		_, err := reader.Read(make([]byte, 32))
+		result <- err
+	}()
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("expected %v, but got %v", context.Canceled, err)
+	}
+
+	// other readers are not affected
+	close(source.release)
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	// the canceled reader can still read buffered data
+	n, err := reader.Read(buf)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf[:n]) {
+		t.Fatalf("expected %v, but got %v", payload, buf[:n])
+	}
+}
+
+func TestReset(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+
+	reader := factory.NewDoppelganger()
+	resetter := reader.(interface{ Reset() error })
+
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+		if err := resetter.Reset(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+
+	if err := factory.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := resetter.Reset(); err != doppelgangerreader.ErrFactoryClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryClosed, err)
+	}
+}
+
+func TestBufferedBytes(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	if n := factory.BufferedBytes(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+	if factory.SourceEOF() {
+		t.Fatalf("expected source not to be at EOF")
+	}
+
+	reader := factory.NewDoppelganger()
+	readAtLeast(t, reader, 5)
+	if n := factory.BufferedBytes(); n != 5 {
+		t.Fatalf("expected 5, but got %d", n)
+	}
+
+	if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.BufferedBytes(); n != int64(len(payload)) {
+		t.Fatalf("expected %d, but got %d", len(payload), n)
+	}
+	if !factory.SourceEOF() {
+		t.Fatalf("expected source to be at EOF")
+	}
+}
+
+func TestReadByte(t *testing.T) {
+	payload := []byte("Hi")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger().(io.ByteReader)
+	for _, expected := range payload {
+		b, err := reader.ReadByte()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if b != expected {
+			t.Fatalf("expected %v, but got %v", expected, b)
+		}
+	}
+	b, err := reader.ReadByte()
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+	if b != 0 {
+		t.Fatalf("expected 0, but got %v", b)
+	}
+}
+
+func TestUnreadByte(t *testing.T) {
+	payload := []byte("Hi")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger().(interface {
+		io.Reader
+		io.ByteScanner
+	})
+	if err := reader.UnreadByte(); err != bufio.ErrInvalidUnreadByte {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadByte, err)
+	}
+	for i := 0; i < 2; i++ {
+		b, err := reader.ReadByte()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if b != 'H' {
+			t.Fatalf("expected %v, but got %v", 'H', b)
+		}
+		if i == 0 {
+			if err := reader.UnreadByte(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			// only one byte can be unread
+			if err := reader.UnreadByte(); err != bufio.ErrInvalidUnreadByte {
+				t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadByte, err)
+			}
+		}
+	}
+
+	// Read can not be undone
+	if _, err := reader.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := reader.UnreadByte(); err != bufio.ErrInvalidUnreadByte {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadByte, err)
+	}
+}
+
+func TestUnreadByteAfterPeek(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello"))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger().(doppelgangerreader.Doppelganger)
+	if _, err := reader.(io.ByteReader).ReadByte(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := reader.Peek(2); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	// like with bufio.Reader, Peek invalidates UnreadByte
+	if err := reader.(io.ByteScanner).UnreadByte(); err != bufio.ErrInvalidUnreadByte {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadByte, err)
+	}
+}
+
+func TestReadRune(t *testing.T) {
+	payload := []byte("Hä€😀\xff!")
+	// a chunk size of 1 splits every multi-byte character over multiple chunks and source reads
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithChunkSize(1))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger().(io.RuneReader)
+	expected := []struct {
+		r    rune
+		size int
+	}{
+		{'H', 1}, {'ä', 2}, {'€', 3}, {'😀', 4}, {utf8.RuneError, 1}, {'!', 1},
+	}
+	for _, e := range expected {
+		r, size, err := reader.ReadRune()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if r != e.r || size != e.size {
+			t.Fatalf("expected %q (%d), but got %q (%d)", e.r, e.size, r, size)
+		}
+	}
+	if _, _, err := reader.ReadRune(); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+
+	// an incomplete character at the end of the stream
+	factory = doppelgangerreader.NewFactory(bytes.NewReader([]byte("€")[:2]))
+	defer factory.Close()
+	reader = factory.NewDoppelganger().(io.RuneReader)
+	if r, size, err := reader.ReadRune(); err != nil || r != utf8.RuneError || size != 1 {
+		t.Fatalf("expected %q (1), but got %q (%d) %v", utf8.RuneError, r, size, err)
+	}
+}
+
+func TestUnreadRune(t *testing.T) {
+	payload := []byte("H😀!")
+	// the emoji crosses the chunk boundaries
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithChunkSize(2))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger().(io.RuneScanner)
+	if err := reader.UnreadRune(); err != bufio.ErrInvalidUnreadRune {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadRune, err)
+	}
+	if r, _, err := reader.ReadRune(); err != nil || r != 'H' {
+		t.Fatalf("expected %q, but got %q (%v)", 'H', r, err)
+	}
+	for i := 0; i < 2; i++ {
+		if r, size, err := reader.ReadRune(); err != nil || r != '😀' || size != 4 {
+			t.Fatalf("expected %q (4), but got %q (%d) %v", '😀', r, size, err)
+		}
+		if err := reader.UnreadRune(); err != nil {
+			t.Fatal(err)
+		}
+		// only the last rune can be unread
+		if err := reader.UnreadRune(); err != bufio.ErrInvalidUnreadRune {
+			t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadRune, err)
+		}
+	}
+
+	if _, err := reader.(io.ByteReader).ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.UnreadRune(); err != bufio.ErrInvalidUnreadRune {
+		t.Fatalf("expected %v, but got %v", bufio.ErrInvalidUnreadRune, err)
+	}
+}
+
+func TestReadBytes(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewBufferString("Hello\nWorld\n!")))
+	defer factory.Close()
+
+	type bytesReader interface {
+		ReadBytes(delim byte) ([]byte, error)
+		ReadString(delim byte) (string, error)
+	}
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	r, ok := reader.(bytesReader)
+	if !ok {
+		t.Fatal("expected reader to implement ReadBytes and ReadString")
+	}
+
+	if line, err := r.ReadBytes('\n'); err != nil || string(line) != "Hello\n" {
+		t.Fatalf("expected %q, but got %q (%v)", "Hello\n", line, err)
+	}
+	if line, err := r.ReadString('\n'); err != nil || line != "World\n" {
+		t.Fatalf("expected %q, but got %q (%v)", "World\n", line, err)
+	}
+	if line, err := r.ReadString('\n'); err != io.EOF || line != "!" {
+		t.Fatalf("expected %q, but got %q (%v)", "!", line, err)
+	}
+	if line, err := r.ReadBytes('\n'); err != io.EOF || len(line) != 0 {
+		t.Fatalf("expected %q, but got %q (%v)", "", line, err)
+	}
+
+	// other doppelgangers are not affected
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+	if buf, _ := ioutil.ReadAll(reader2); string(buf) != "Hello\nWorld\n!" {
+		t.Fatalf("expected %q, but got %q", "Hello\nWorld\n!", buf)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	if err := factory.Freeze(); err != doppelgangerreader.ErrSourceNotEOF {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrSourceNotEOF, err)
+	}
+	if err := factory.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.Freeze(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		reader := factory.NewDoppelganger()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+			if buf, err := ioutil.ReadAll(iotest.OneByteReader(reader)); err != nil || !bytes.Equal(payload, buf) {
+				t.Errorf("expected %v, but got %v (%v)", payload, buf, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := factory.Reset(bytes.NewReader(payload)); err != doppelgangerreader.ErrFactoryFrozen {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryFrozen, err)
+	}
+	if err := factory.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.Freeze(); err != doppelgangerreader.ErrFactoryClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryClosed, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer src.Close()
+	srcReader := src.NewDoppelganger()
+	defer srcReader.Close()
+	if buf := read(t, srcReader, 5); string(buf) != "Hello" {
+		t.Fatalf("expected %q, but got %q", "Hello", buf)
+	}
+
+	dst := doppelgangerreader.NewFactory(bytes.NewBufferString("something else"))
+	dstReader := dst.NewDoppelganger()
+	defer dstReader.Close()
+	if buf := read(t, dstReader, 2); string(buf) != "so" {
+		t.Fatalf("expected %q, but got %q", "so", buf)
+	}
+
+	if err := src.Copy(dst); err != nil {
+		t.Fatal(err)
+	}
+	// active doppelgangers keep their position
+	if buf, _ := ioutil.ReadAll(dstReader); string(buf) != "llo World" {
+		t.Fatalf("expected %q, but got %q", "llo World", buf)
+	}
+	dstReader2 := dst.NewDoppelganger()
+	defer dstReader2.Close()
+	if buf, _ := ioutil.ReadAll(dstReader2); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+
+	// the buffers are independent
+	if err := dst.Trim(11); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected %d, but got %d", 1, n)
+	}
+	if buf, _ := ioutil.ReadAll(srcReader); string(buf) != " World" {
+		t.Fatalf("expected %q, but got %q", " World", buf)
+	}
+	srcReader2 := src.NewDoppelganger()
+	defer srcReader2.Close()
+	if buf, _ := ioutil.ReadAll(srcReader2); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+
+	if err := src.Copy(src); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestSnapshotReader(t *testing.T) {
+	source := &blockingReader{
+		data:    []byte("Hello World"),
+		release: make(chan struct{}, 1),
+	}
+	factory := doppelgangerreader.NewFactoryWithOptions(source, doppelgangerreader.WithChunkSize(5))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	source.release <- struct{}{}
+	read(t, reader, 5)
+
+	snapshot := factory.SnapshotReader()
+	if n := factory.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected %d, but got %d", 1, n)
+	}
+	// the source is blocked, the snapshot must not wait for it
+	if buf, err := ioutil.ReadAll(snapshot); err != nil || string(buf) != "Hello" {
+		t.Fatalf("expected %q, but got %q (%v)", "Hello", buf, err)
+	}
+	close(source.release)
+}
+
+func TestCloseError(t *testing.T) {
+	err1 := errors.New("first")
+	err2 := errors.New("second")
+	var err error = &doppelgangerreader.CloseError{Errs: []error{err1, err2}}
+
+	if msg := err.Error(); msg != "first\nsecond" {
+		t.Fatalf("expected %q, but got %q", "first\nsecond", msg)
+	}
+	if !errors.Is(err, err2) {
+		t.Fatalf("expected %v to contain %v", err, err2)
+	}
+	if errors.Is(err, io.EOF) {
+		t.Fatalf("expected %v not to contain %v", err, io.EOF)
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != 2 || errs[0] != err1 || errs[1] != err2 {
+		t.Fatalf("expected %v, but got %v", []error{err1, err2}, errs)
+	}
+}
+
+type failCloser struct {
+	io.Reader
+	err error
+}
+
+func (c failCloser) Close() error {
+	return c.err
+}
+
+type printfLogger struct {
+	messages []string
+}
+
+func (l *printfLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestMustClose(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	factory.MustClose()
+
+	closeErr := errors.New("close failed")
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		failCloser{Reader: bytes.NewBufferString("Hello World"), err: closeErr},
+		doppelgangerreader.WithCloseSource(),
+	)
+	defer func() {
+		if r := recover(); r != closeErr {
+			t.Fatalf("expected %v, but got %v", closeErr, r)
+		}
+	}()
+	factory.MustClose()
+	t.Fatal("expected a panic")
+}
+
+func TestCloseAndLog(t *testing.T) {
+	var logger printfLogger
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	factory.CloseAndLog(&logger)
+	if len(logger.messages) != 0 {
+		t.Fatalf("expected no messages, but got %v", logger.messages)
+	}
+
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		failCloser{Reader: bytes.NewBufferString("Hello World"), err: errors.New("close failed")},
+		doppelgangerreader.WithCloseSource(),
+	)
+	factory.CloseAndLog(&logger)
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "close failed") {
+		t.Fatalf("expected a message about %q, but got %v", "close failed", logger.messages)
+	}
+}
+
+func TestTail(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{5, "World"},
+		{0, ""},
+		{11, "Hello World"},
+		{100, "Hello World"},
+	}
+	for _, test := range tests {
+		reader, err := factory.Tail(test.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf, _ := ioutil.ReadAll(reader); string(buf) != test.expected {
+			t.Fatalf("expected %q, but got %q", test.expected, buf)
+		}
+		_ = reader.Close()
+	}
+}
+
+func TestTailWithBufferEviction(t *testing.T) {
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewBufferString("Hello World"), doppelgangerreader.WithBufferEviction())
+	defer factory.Close()
+
+	// an open doppelganger keeps the tail buffered
+	keeper, err := factory.NewDoppelgangerAt(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := factory.Tail(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := ioutil.ReadAll(reader); string(buf) != "rld" {
+		t.Fatalf("expected %q, but got %q", "rld", buf)
+	}
+	_ = reader.Close()
+
+	// once the whole stream has been read the tail is evicted
+	if _, err = ioutil.ReadAll(keeper); err != nil {
+		t.Fatal(err)
+	}
+	_ = keeper.Close()
+	if _, err = factory.Tail(3); err != doppelgangerreader.ErrEvicted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrEvicted, err)
+	}
+	reader, err = factory.Tail(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := ioutil.ReadAll(reader); len(buf) != 0 {
+		t.Fatalf("expected %q, but got %q", "", buf)
+	}
+	_ = reader.Close()
+}
+
+func TestTailSourceError(t *testing.T) {
+	expected := errors.New("source failed")
+	factory := doppelgangerreader.NewFactory(&errorReader{err: expected})
+	defer factory.Close()
+
+	if _, err := factory.Tail(5); !errors.Is(err, expected) {
+		t.Fatalf("expected %v, but got %v", expected, err)
+	}
+}
+
+func TestLimitedSource(t *testing.T) {
+	data := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(io.LimitReader(bytes.NewReader(data), int64(len(data))))
+	defer factory.Close()
+
+	if n, ok := factory.Remaining(); !ok || n != int64(len(data)) {
+		t.Fatalf("expected %d, but got %d (%v)", len(data), n, ok)
+	}
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf) {
+		t.Fatalf("expected %v, but got %v", data, buf)
+	}
+	if n, ok := factory.Remaining(); !ok || n != 0 {
+		t.Fatalf("expected %d, but got %d (%v)", 0, n, ok)
+	}
+}
+
+func TestLimitedSourceEmpty(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(io.LimitReader(bytes.NewReader([]byte("Hello World")), 0))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 0 {
+		t.Fatalf("expected %v, but got %v", []byte{}, buf)
+	}
+	if !factory.SourceEOF() {
+		t.Fatal("expected the source to be at EOF")
+	}
+	if n, ok := factory.Remaining(); !ok || n != 0 {
+		t.Fatalf("expected %d, but got %d (%v)", 0, n, ok)
+	}
+}
+
+func TestRemainingUnknown(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewReader([]byte("Hello World")))
+	defer factory.Close()
+
+	if n, ok := factory.Remaining(); ok {
+		t.Fatalf("expected an unknown remaining size, but got %d", n)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewBufferString("Hello World"), doppelgangerreader.WithChunkSize(2))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+
+	readAtLeast(t, reader, 6)
+
+	if err := factory.Trim(8); err != doppelgangerreader.ErrDoppelgangerBehindTrimPoint {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDoppelgangerBehindTrimPoint, err)
+	}
+	if n := factory.TrimWatermark(); n != 0 {
+		t.Fatalf("expected %d, but got %d", 0, n)
+	}
+
+	if err := factory.Trim(4); err != nil {
+		t.Fatal(err)
+	}
+	if n := factory.TrimWatermark(); n != 4 {
+		t.Fatalf("expected %d, but got %d", 4, n)
+	}
+	if n := factory.BufferedBytes(); n != 2 {
+		t.Fatalf("expected %d, but got %d", 2, n)
+	}
+
+	if _, err := factory.NewDoppelgangerAt(3); err != doppelgangerreader.ErrEvicted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrEvicted, err)
+	}
+	if _, err := reader.(io.Seeker).Seek(0, io.SeekStart); err != doppelgangerreader.ErrEvicted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrEvicted, err)
+	}
+
+	reader2, err := factory.NewDoppelgangerAt(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader2.Close()
+	if buf, _ := ioutil.ReadAll(reader2); string(buf) != "o World" {
+		t.Fatalf("expected %q, but got %q", "o World", buf)
+	}
+	if buf, _ := ioutil.ReadAll(reader); string(buf) != "World" {
+		t.Fatalf("expected %q, but got %q", "World", buf)
+	}
+}
+
+func TestTrimWithoutDoppelgangers(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	if err := factory.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	// trimming beyond the end is limited to the buffered data
+	if err := factory.Trim(100); err != nil {
+		t.Fatal(err)
+	}
+	if n := factory.TrimWatermark(); n != 11 {
+		t.Fatalf("expected %d, but got %d", 11, n)
+	}
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	if buf, _ := ioutil.ReadAll(reader); len(buf) != 0 {
+		t.Fatalf("expected %q, but got %q", "", buf)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 10000)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	if err := factory.Prefetch(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := factory.Prefetch(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !factory.SourceEOF() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected source to be at EOF")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := factory.BufferedBytes(); n != int64(len(payload)) {
+		t.Fatalf("expected %d, but got %d", len(payload), n)
+	}
+
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestPrefetchError(t *testing.T) {
+	sourceErr := errors.New("source error")
+	source := io.MultiReader(bytes.NewReader([]byte("Hello World")), &errorReader{sourceErr})
+	factory := doppelgangerreader.NewFactory(source)
+	defer factory.Close()
+
+	if err := factory.Prefetch(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if !errors.Is(err, sourceErr) {
+		t.Fatalf("expected %v, but got %v", sourceErr, err)
+	}
+	if !bytes.Equal([]byte("Hello World"), buf) {
+		t.Fatalf("expected %v, but got %v", []byte("Hello World"), buf)
+	}
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+type failWriter struct {
+	err error
+}
+
+func (w failWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestWriteTo(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 10000)
+
+	t.Run("success", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		reader := factory.NewDoppelganger()
+		start := readAtLeast(t, reader, 5)
+
+		var buf bytes.Buffer
+		n, err := reader.(io.WriterTo).WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != int64(len(payload)-5) {
+			t.Fatalf("expected %d, but got %d", len(payload)-5, n)
+		}
+		if !bytes.Equal(payload, append(start, buf.Bytes()...)) {
+			t.Fatalf("expected payload to be equal")
+		}
+
+		// the position must be at the end
+		if _, err := reader.Read(start); err != io.EOF {
+			t.Fatalf("expected io.EOF, but got %v", err)
+		}
+	})
+
+	t.Run("writer error", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		writeErr := errors.New("write error")
+		_, err := io.Copy(failWriter{writeErr}, factory.NewDoppelganger())
+		if err != writeErr {
+			t.Fatalf("expected %v, but got %v", writeErr, err)
+		}
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		sourceErr := errors.New("source error")
+		factory := doppelgangerreader.NewFactory(&errorReader{sourceErr})
+		defer factory.Close()
+
+		_, err := io.Copy(ioutil.Discard, factory.NewDoppelganger())
+		if !errors.Is(err, sourceErr) {
+			t.Fatalf("expected %v, but got %v", sourceErr, err)
+		}
+	})
+}
+
+func TestNewDoppelgangerAt(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader, err := factory.NewDoppelgangerAt(6)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+
+	// offset is already buffered
+	reader, err = factory.NewDoppelgangerAt(2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf, err = ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[2:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[2:], buf)
+	}
+
+	if _, err := factory.NewDoppelgangerAt(int64(len(payload) + 1)); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+}
+
+func TestSeekRelative(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	seeker := reader.(interface {
+		SeekRelative(delta int64) (int64, error)
+	})
+
+	pos, err := seeker.SeekRelative(6)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if pos != 6 {
+		t.Fatalf("expected 6, but got %d", pos)
+	}
+
+	pos, err = seeker.SeekRelative(-2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if pos != 4 {
+		t.Fatalf("expected 4, but got %d", pos)
+	}
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[4:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[4:], buf)
+	}
+
+	if _, err := seeker.SeekRelative(-100); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestBytes(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	if b := factory.Bytes(); len(b) != 0 {
+		t.Fatalf("expected empty slice, but got %v", b)
+	}
+
+	reader := factory.NewDoppelganger()
+	readAtLeast(t, reader, 5)
+
+	b := factory.Bytes()
+	if !bytes.Equal(payload[:5], b) {
+		t.Fatalf("expected %v, but got %v", payload[:5], b)
+	}
+
+	// modifying the copy must not modify the buffer
+	b[0] = 'X'
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestListDoppelgangers(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+	if n := factory.ActiveDoppelgangerCount(); n != 2 {
+		t.Fatalf("expected 2, but got %d", n)
+	}
+
+	list := factory.ListDoppelgangers()
+	if len(list) != 2 || list[0] != reader1 || list[1] != reader2 {
+		t.Fatalf("expected %v, but got %v", []io.ReadCloser{reader1, reader2}, list)
+	}
+
+	// modifying the snapshot must not affect the factory
+	list[0] = nil
+	if err := reader1.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected 1, but got %d", n)
+	}
+	list = factory.ListDoppelgangers()
+	if len(list) != 1 || list[0] != reader2 {
+		t.Fatalf("expected %v, but got %v", []io.ReadCloser{reader2}, list)
+	}
+
+	// nested factories only list their own doppelgangers
+	nested := doppelgangerreader.NewFactory(reader2)
+	nestedReader := nested.NewDoppelganger()
+	if n := nested.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected 1, but got %d", n)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 2 {
+		t.Fatalf("expected 2, but got %d", n)
+	}
+	if err := nestedReader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := nested.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 10000)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	readAtLeast(t, reader, 5)
+
+	for i := 0; i < 2; i++ {
+		if err := factory.Drain(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !factory.SourceEOF() {
+			t.Fatalf("expected source to be at EOF")
+		}
+		if n := factory.BufferedBytes(); n != int64(len(payload)) {
+			t.Fatalf("expected %d, but got %d", len(payload), n)
+		}
+	}
+
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected payload to be equal")
+	}
+
+	sourceErr := errors.New("source error")
+	factory = doppelgangerreader.NewFactory(&errorReader{sourceErr})
+	defer factory.Close()
+	err = factory.Drain()
+	if !doppelgangerreader.IsSourceError(err) {
+		t.Fatalf("expected a source error, but got %v", err)
+	}
+	if err := doppelgangerreader.UnwrapSourceError(err); err != sourceErr {
+		t.Fatalf("expected %v, but got %v", sourceErr, err)
+	}
+	if doppelgangerreader.IsSourceError(doppelgangerreader.ErrFactoryClosed) {
+		t.Fatalf("expected %v not to be a source error", doppelgangerreader.ErrFactoryClosed)
+	}
+	if err := doppelgangerreader.UnwrapSourceError(io.EOF); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+}
+
+func TestCloseAllDoppelgangers(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+	readAtLeast(t, reader1, 5)
+
+	if err := factory.CloseAllDoppelgangers(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+	for _, reader := range []io.Reader{reader1, reader2} {
+		if _, err := reader.Read(make([]byte, 1)); err != doppelgangerreader.ErrDoppelgangerClosed {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDoppelgangerClosed, err)
+		}
+	}
+
+	// the factory is still usable
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	positioner, ok := reader.(doppelgangerreader.Positioner)
+	if !ok {
+		t.Fatalf("expected reader to implement Positioner")
+	}
+	if pos := positioner.Position(); pos != 0 {
+		t.Fatalf("expected 0, but got %d", pos)
+	}
+	readAtLeast(t, reader, 5)
+	if pos := positioner.Position(); pos != 5 {
+		t.Fatalf("expected 5, but got %d", pos)
+	}
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if pos := positioner.Position(); pos != 11 {
+		t.Fatalf("expected 11, but got %d", pos)
+	}
+}
+
+func TestSetReadDeadline(t *testing.T) {
+	payload := []byte("Hello World")
+	source := &blockingReader{
+		data:    payload,
+		release: make(chan struct{}),
+	}
+	factory := doppelgangerreader.NewFactory(source)
+	defer factory.Close()
+
+	type deadliner interface {
+		io.Reader
+		SetReadDeadline(t time.Time) error
+	}
+	reader := factory.NewDoppelganger().(deadliner)
+	other := factory.NewDoppelganger().(deadliner)
+
+	// a deadline in the past fails immediately
+	if err := reader.SetReadDeadline(time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := reader.Read(make([]byte, 32)); !os.IsTimeout(err) {
+		t.Fatalf("expected a timeout, but got %v", err)
+	}
+
+	// a waiting read fails once the deadline passed
+	if err := reader.SetReadDeadline(time.Now().Add(time.Millisecond * 50)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	_, err := reader.Read(make([]byte, 32))
+	if _, ok := err.(doppelgangerreader.DeadlineExceeded); !ok {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.DeadlineExceeded{}, err)
+	}
+
+	// the deadline also applies to context doppelgangers
+	ctxReader := factory.NewContextDoppelganger(context.Background()).(deadliner)
+	if err := ctxReader.SetReadDeadline(time.Now().Add(time.Millisecond * 50)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := ctxReader.Read(make([]byte, 32)); !os.IsTimeout(err) {
+		t.Fatalf("expected a timeout, but got %v", err)
+	}
+
+	// clearing the deadline enables blocking reads again, other readers are not affected
+	if err := reader.SetReadDeadline(time.Time{}); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	close(source.release)
+	for _, r := range []io.Reader{reader, other} {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+}
+
+func TestPeek(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	peeker := reader.(interface {
+		Peek(n int) ([]byte, error)
+	})
+
+	buf, err := peeker.Peek(5)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[:5], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:5], buf)
+	}
+
+	// peeking does not advance the reader
+	buf = readAtLeast(t, reader, 6)
+	if !bytes.Equal(payload[:6], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:6], buf)
+	}
+
+	// fewer bytes than requested
+	buf, err = peeker.Peek(10)
+	if err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+	if !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	// no active doppelgangers
+	if err := factory.WaitAll(context.Background()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := factory.WaitAll(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, but got %v", context.DeadlineExceeded, err)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- factory.WaitAll(context.Background())
+	}()
+
+	if _, err := ioutil.ReadAll(reader1); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	readAtLeast(t, reader2, 5)
+	if err := reader2.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestNewFactoryWithData(t *testing.T) {
+	payload := []byte("Hello World")
+	source := bytes.NewReader(payload)
+
+	// detect the content type and hand the consumed bytes over to the factory
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(source, prefix); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	factory := doppelgangerreader.NewFactoryWithData(prefix, source)
+	defer factory.Close()
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+
+	// an empty prefix
+	factory = doppelgangerreader.NewFactoryWithData(nil, bytes.NewReader(payload))
+	defer factory.Close()
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	discarder := reader.(interface {
+		Discard(n int64) (int64, error)
+	})
+
+	n, err := discarder.Discard(6)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n != 6 {
+		t.Fatalf("expected 6, but got %d", n)
+	}
+	buf := readAtLeast(t, reader, 2)
+	if !bytes.Equal(payload[6:8], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:8], buf)
+	}
+
+	n, err = discarder.Discard(10)
+	if err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3, but got %d", n)
+	}
+}
+
+func TestFork(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	child, err := factory.Fork(6)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(child.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload[6:], buf) {
+			t.Fatalf("expected %v, but got %v", payload[6:], buf)
+		}
+	}
+
+	// closing the child does not affect the parent
+	if err = child.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	if _, err = factory.Fork(20); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+}
+
+func TestForkReset(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	child, err := factory.Fork(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the child releases its doppelganger of the parent when it switches to r
+	r := &closeCounter{Reader: bytes.NewBufferString("Goodbye")}
+	if err = child.Reset(r); err != nil {
+		t.Fatal(err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected %d, but got %d", 0, n)
+	}
+	if buf, _ := ioutil.ReadAll(child.NewDoppelganger()); string(buf) != "Goodbye" {
+		t.Fatalf("expected %q, but got %q", "Goodbye", buf)
+	}
+
+	// like Reset documents, r is not closed along with the child
+	if err = child.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r.closes != 0 {
+		t.Fatalf("expected %d, but got %d", 0, r.closes)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected %d, but got %d", 0, n)
+	}
+}
+
+func TestNamedDoppelgangers(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader, err := factory.NewNamedDoppelganger("logger")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err = factory.NewNamedDoppelganger("logger"); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected 1, but got %d", n)
+	}
+
+	found, ok := factory.GetDoppelganger("logger")
+	if !ok || found != reader {
+		t.Fatalf("expected %v, but got %v", reader, found)
+	}
+	list := factory.ListDoppelgangers()
+	if name := list[0].(interface{ Name() string }).Name(); name != "logger" {
+		t.Fatalf("expected logger, but got %s", name)
+	}
+
+	if err = factory.RemoveDoppelgangerByName("logger"); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, ok = factory.GetDoppelganger("logger"); ok {
+		t.Fatalf("expected logger to be removed")
+	}
+	if err = factory.RemoveDoppelgangerByName("logger"); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	// the name can be used again
+	if _, err = factory.NewNamedDoppelganger("logger"); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestFactoryReset(t *testing.T) {
+	source := &blockingReader{
+		data:    []byte("Hello World"),
+		release: make(chan struct{}),
+	}
+	factory := doppelgangerreader.NewFactory(source)
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	result := make(chan error)
+	go func() {
+		_, err := reader.Read(make([]byte, 32))
+		result <- err
+	}()
+
+	// give the reader some time to block
+	time.Sleep(time.Millisecond * 50)
+	payload := []byte("Goodbye")
+	if err := factory.Reset(bytes.NewReader(payload)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := <-result; err != doppelgangerreader.ErrFactoryReset {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryReset, err)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+
+	// the pending read on the old source does not affect the new buffer
+	close(source.release)
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+
+	factory.Close()
+	if err := factory.Reset(bytes.NewReader(payload)); err != doppelgangerreader.ErrFactoryClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryClosed, err)
+	}
+}
+
+func TestFactoryWriteTo(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 10000)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	readAtLeast(t, reader, 100)
+
+	var out bytes.Buffer
+	n, err := factory.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Fatalf("expected %d, but got %d", len(payload), n)
+	}
+	if !bytes.Equal(payload, out.Bytes()) {
+		t.Fatalf("expected %d bytes, but got %d", len(payload), out.Len())
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 1 {
+		t.Fatalf("expected 1, but got %d", n)
+	}
+
+	// the doppelgangers see the data that has been read by WriteTo
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[100:], rest) {
+		t.Fatalf("expected %d bytes, but got %d", len(payload)-100, len(rest))
+	}
+}
+
+func TestRewind(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader, err := factory.NewNamedDoppelganger("rewinder")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	rewinder := reader.(interface{ Rewind() error })
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+		if err = rewinder.Rewind(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+	// the reader is still the same
+	if found, ok := factory.GetDoppelganger("rewinder"); !ok || found != reader {
+		t.Fatalf("expected %v, but got %v", reader, found)
+	}
+
+	reader.Close()
+	if err = rewinder.Rewind(); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestDoppelgangerInterface(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	var reader io.ReadCloser = factory.NewDoppelganger()
+	defer reader.Close()
+	d, ok := reader.(doppelgangerreader.Doppelganger)
+	if !ok || !d.IsDoppelganger() {
+		t.Fatal("expected the reader to be a Doppelganger")
+	}
+	if buf, err := d.Peek(5); err != nil || !bytes.Equal(payload[:5], buf) {
+		t.Fatalf("expected %v, but got %v (%v)", payload[:5], buf, err)
+	}
+	if buf := read(t, reader, 5); !bytes.Equal(payload[:5], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:5], buf)
+	}
+	if pos := d.Position(); pos != 5 {
+		t.Fatalf("expected %d, but got %d", 5, pos)
+	}
+	if err := d.Rewind(); err != nil {
+		t.Fatal(err)
+	}
+	if pos := d.Position(); pos != 0 {
+		t.Fatalf("expected %d, but got %d", 0, pos)
+	}
+
+	if _, ok := io.Reader(bytes.NewReader(payload)).(doppelgangerreader.Doppelganger); ok {
+		t.Fatal("expected a plain reader not to be a Doppelganger")
+	}
+}
+
+func BenchmarkFactory_NReaders(b *testing.B) {
+	payload := bytes.Repeat([]byte("a"), 1024*1024)
+	for _, n := range []int{1, 2, 4, 8, 16, 32} {
+		b.Run(fmt.Sprintf("N=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(n * len(payload)))
+			for i := 0; i < b.N; i++ {
+				factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+				var wg sync.WaitGroup
+				for j := 0; j < n; j++ {
+					reader := factory.NewDoppelganger()
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+				factory.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkFactory_StragglerEffect(b *testing.B) {
+	payload := bytes.Repeat([]byte("a"), 1024*1024)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	var buffered int64
+	for i := 0; i < b.N; i++ {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithBufferEviction())
+		fast := factory.NewDoppelganger()
+		slow := factory.NewDoppelganger()
+
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			// read a single byte per second
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			p := make([]byte, 1)
+			for {
+				if _, err := slow.Read(p); err != nil {
+					return
+				}
+				select {
+				case <-ticker.C:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		if _, err := io.Copy(ioutil.Discard, fast); err != nil {
+			b.Fatal(err)
+		}
+		// the straggler holds back the eviction
+		buffered += factory.BufferedBytes()
+		close(done)
+		factory.Close()
+		<-stopped
+	}
+	b.ReportMetric(float64(buffered)/float64(b.N), "buffered-bytes/op")
+}
+
+func BenchmarkFactory_ShortLived(b *testing.B) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reader := factory.NewDoppelganger()
+		if err := reader.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkConcurrentRead(b *testing.B, freeze bool) {
+	payload := bytes.Repeat([]byte("Hello World"), 100000)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+	if err := factory.Drain(); err != nil {
+		b.Fatal(err)
+	}
+	if freeze {
+		if err := factory.Freeze(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		p := make([]byte, 64)
+		reader := factory.NewDoppelganger()
+		defer reader.Close()
+		for pb.Next() {
+			if _, err := reader.Read(p); err == io.EOF {
+				_, _ = reader.(io.Seeker).Seek(0, io.SeekStart)
+			}
+		}
+	})
+}
+
+func BenchmarkConcurrentRead(b *testing.B) {
+	benchmarkConcurrentRead(b, false)
+}
+
+func BenchmarkConcurrentReadFrozen(b *testing.B) {
+	benchmarkConcurrentRead(b, true)
+}
+
+func benchmarkSequentialRead(b *testing.B, opts ...doppelgangerreader.Option) {
+	payload := bytes.Repeat([]byte("Hello World"), 100000)
+	p := make([]byte, 512)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	for i := 0; i < b.N; i++ {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), opts...)
+		reader := factory.NewDoppelganger()
+		for {
+			// small reads, io.Copy would use WriteTo
+			if _, err := reader.Read(p); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+		factory.Close()
+	}
+}
+
+func BenchmarkSequentialRead(b *testing.B) {
+	benchmarkSequentialRead(b)
+}
+
+func BenchmarkSequentialReadNoLock(b *testing.B) {
+	benchmarkSequentialRead(b, doppelgangerreader.WithNoLock())
+}
+
+func BenchmarkFactory(b *testing.B) {
+	payload := bytes.Repeat([]byte("Hello World"), 100000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	for i := 0; i < b.N; i++ {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		reader1 := factory.NewDoppelganger()
+		reader2 := factory.NewDoppelganger()
+		if _, err := io.Copy(ioutil.Discard, reader1); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(ioutil.Discard, reader2); err != nil {
+			b.Fatal(err)
+		}
+		factory.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// NewEOFCallbackDoppelganger creates a new reader that acts like the original reader
+// and calls fn with the number of bytes it delivered the first time Read returns io.EOF.
+// fn will not be called if the reader is closed before reaching the end of the stream.
+func NewEOFCallbackDoppelganger(factory DoppelgangerFactory, fn func(totalBytesRead int64)) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &eofCallbackReader{
+		Reader: reader,
+		Closer: reader,
+		fn:     fn,
+	}
+}
+
+type eofCallbackReader struct {
+	io.Reader
+	io.Closer
+	fn     func(totalBytesRead int64)
+	total  int64
+	called bool
+}
+
+func (r *eofCallbackReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.total += int64(n)
+	if err == io.EOF && !r.called {
+		r.called = true
+		r.fn(r.total)
+	}
+	return n, err
+}
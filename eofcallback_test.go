@@ -0,0 +1,43 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewEOFCallbackDoppelganger(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	var calls []int64
+	reader := doppelgangerreader.NewEOFCallbackDoppelganger(factory, func(totalBytesRead int64) {
+		calls = append(calls, totalBytesRead)
+	})
+	defer reader.Close()
+
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	// reading again must not call fn again
+	_, _ = reader.Read(make([]byte, 1))
+
+	if len(calls) != 1 || calls[0] != 11 {
+		t.Fatalf("expected %v, but got %v", []int64{11}, calls)
+	}
+
+	// closing before EOF does not call fn
+	called := false
+	reader = doppelgangerreader.NewEOFCallbackDoppelganger(factory, func(int64) {
+		called = true
+	})
+	readAtLeast(t, reader, 5)
+	if err := reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if called {
+		t.Fatalf("expected fn not to be called")
+	}
+}
@@ -0,0 +1,66 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrUnexpectedLength will be reported by doppelgangers of NewExactLengthDoppelganger
+// if the stream is shorter or longer than expected
+var ErrUnexpectedLength = errors.New("stream does not have the expected length")
+
+// NewExactLengthDoppelganger creates a new reader that acts like the original reader but enforces
+// that the stream is exactly expectedLength bytes long, e.g. to validate a Content-Length.
+// The reader never delivers more than expectedLength bytes, instead of io.EOF it returns ErrUnexpectedLength
+// if the stream ends early or if there is more data after expectedLength bytes.
+func NewExactLengthDoppelganger(factory DoppelgangerFactory, expectedLength int64) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &exactLengthReader{
+		Reader:    reader,
+		Closer:    reader,
+		remaining: expectedLength,
+	}
+}
+
+type exactLengthReader struct {
+	io.Reader
+	io.Closer
+	remaining int64
+	err       error
+}
+
+func (r *exactLengthReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.remaining <= 0 {
+		r.err = r.checkEnd()
+		return 0, r.err
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.Reader.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF {
+		err = ErrUnexpectedLength
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// checkEnd makes sure the stream ends after the expected length
+func (r *exactLengthReader) checkEnd() error {
+	var b [1]byte
+	for {
+		n, err := r.Reader.Read(b[:])
+		if n > 0 {
+			return ErrUnexpectedLength
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewExactLengthDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	tests := []struct {
+		length   int64
+		expected []byte
+		err      error
+	}{
+		{length: 11, expected: payload, err: nil},
+		{length: 20, expected: payload, err: doppelgangerreader.ErrUnexpectedLength},
+		{length: 5, expected: payload[:5], err: doppelgangerreader.ErrUnexpectedLength},
+	}
+	for _, test := range tests {
+		reader := doppelgangerreader.NewExactLengthDoppelganger(factory, test.length)
+		buf, err := ioutil.ReadAll(reader)
+		if err != test.err {
+			t.Fatalf("expected %v, but got %v", test.err, err)
+		}
+		if !bytes.Equal(test.expected, buf) {
+			t.Fatalf("expected %v, but got %v", test.expected, buf)
+		}
+		// the result is sticky
+		if _, err = reader.Read(make([]byte, 1)); err != test.err && !(test.err == nil && err == io.EOF) {
+			t.Fatalf("expected %v, but got %v", test.err, err)
+		}
+		reader.Close()
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"io/ioutil"
 	"net/http"
 
-	"io"
 	"log"
 
 	"github.com/Eun/go-doppelgangerreader"
@@ -72,7 +71,7 @@ func (e errorHandler) ServeHTTP(writer http.ResponseWriter, request *http.Reques
 	request.Body = factory.NewDoppelganger()
 	defer func() {
 		err := recover()
-		body, _ := ioutil.ReadAll(io.LimitReader(factory.NewDoppelganger(), 128))
+		body, _ := ioutil.ReadAll(doppelgangerreader.NewDoppelgangerLimited(factory, 128))
 		log.Printf("handler panic: %#v, body was %v", err, body)
 		factory.Close()
 	}()
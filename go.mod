@@ -1,3 +1,5 @@
 module github.com/Eun/go-doppelgangerreader
 
 go 1.18
+
+require github.com/andybalholm/brotli v1.1.0
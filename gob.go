@@ -0,0 +1,37 @@
+package doppelgangerreader
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// GobFramedDoppelganger is a doppelganger that reads the stream as gob encoded byte slices
+type GobFramedDoppelganger interface {
+	io.ReadCloser
+	// ReadGobFrame returns the next gob encoded byte slice or io.EOF if there are no more frames
+	ReadGobFrame() ([]byte, error)
+}
+
+// NewGobFramedDoppelganger creates a new reader that acts like the original reader
+// and reads the stream as gob encoded byte slices (as written by gob.Encoder.Encode([]byte)).
+// Use either ReadGobFrame or Read, mixing both will corrupt the gob decoding.
+func NewGobFramedDoppelganger(factory DoppelgangerFactory) GobFramedDoppelganger {
+	reader := factory.NewDoppelganger()
+	return &gobFramedReader{
+		ReadCloser: reader,
+		decoder:    gob.NewDecoder(reader),
+	}
+}
+
+type gobFramedReader struct {
+	io.ReadCloser
+	decoder *gob.Decoder
+}
+
+func (r *gobFramedReader) ReadGobFrame() ([]byte, error) {
+	var frame []byte
+	if err := r.decoder.Decode(&frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
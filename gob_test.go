@@ -0,0 +1,50 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewGobFramedDoppelganger(t *testing.T) {
+	frames := [][]byte{[]byte("Hello"), []byte("World")}
+
+	var buf bytes.Buffer
+	encoder := gob.NewEncoder(&buf)
+	for _, frame := range frames {
+		if err := encoder.Encode(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := buf.Bytes()
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewGobFramedDoppelganger(factory)
+	defer reader.Close()
+	for _, expected := range frames {
+		frame, err := reader.ReadGobFrame()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(expected, frame) {
+			t.Fatalf("expected %v, but got %v", expected, frame)
+		}
+	}
+	if _, err := reader.ReadGobFrame(); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+
+	raw, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, raw) {
+		t.Fatalf("expected %v, but got %v", payload, raw)
+	}
+}
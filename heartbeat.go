@@ -0,0 +1,57 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// NewHeartbeatDoppelganger creates a new reader that acts like the original reader
+// and calls fn every interval while there is buffered data the reader did not consume yet.
+// This tells a consumer that is processing slowly apart from one that is stalled by the source.
+// The heartbeat stops when the reader is closed. (an interval of 0 or less disables the heartbeat)
+func NewHeartbeatDoppelganger(factory DoppelgangerFactory, interval time.Duration, fn func()) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	instance, ok := reader.(*readerInstance)
+	if interval <= 0 || !ok {
+		return reader
+	}
+	r := &heartbeatReader{
+		ReadCloser: reader,
+		stop:       make(chan struct{}),
+	}
+	go r.run(instance, interval, fn)
+	return r
+}
+
+type heartbeatReader struct {
+	io.ReadCloser
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (r *heartbeatReader) run(instance *readerInstance, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		pending, finished := instance.pending()
+		if finished {
+			return
+		}
+		if pending > 0 {
+			fn()
+		}
+	}
+}
+
+func (r *heartbeatReader) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	return r.ReadCloser.Close()
+}
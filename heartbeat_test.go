@@ -0,0 +1,49 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewHeartbeatDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	var beats int32
+	reader := doppelgangerreader.NewHeartbeatDoppelganger(factory, time.Millisecond*5, func() {
+		atomic.AddInt32(&beats, 1)
+	})
+	defer reader.Close()
+
+	// nothing has been buffered yet
+	time.Sleep(time.Millisecond * 30)
+	if n := atomic.LoadInt32(&beats); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+
+	// buffer the data with an other doppelganger
+	if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	time.Sleep(time.Millisecond * 30)
+	if n := atomic.LoadInt32(&beats); n == 0 {
+		t.Fatalf("expected heartbeats")
+	}
+
+	// after catching up the heartbeat stops
+	if _, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	n := atomic.LoadInt32(&beats)
+	time.Sleep(time.Millisecond * 30)
+	if m := atomic.LoadInt32(&beats); m != n {
+		t.Fatalf("expected %d, but got %d", n, m)
+	}
+}
@@ -0,0 +1,53 @@
+package doppelgangerreader
+
+import (
+	"encoding/hex"
+	"io"
+)
+
+// NewHexDoppelganger creates a new reader that acts like the original reader
+// but delivers every byte as two lowercase hex characters.
+// Other doppelgangers will see the original bytes.
+func NewHexDoppelganger(factory DoppelgangerFactory) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &hexReader{
+		Reader: reader,
+		Closer: reader,
+	}
+}
+
+type hexReader struct {
+	io.Reader
+	io.Closer
+	// pending holds encoded data that did not fit into the last read
+	pending []byte
+	raw     []byte
+}
+
+func (r *hexReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.pending) == 0 {
+		// every source byte expands to two hex characters
+		size := len(p) / 2
+		if size == 0 {
+			size = 1
+		}
+		if cap(r.raw) < size {
+			r.raw = make([]byte, size)
+		}
+		n, err := r.Reader.Read(r.raw[:size])
+		if n == 0 {
+			return 0, err
+		}
+		if cap(r.pending) < n*2 {
+			r.pending = make([]byte, n*2)
+		}
+		r.pending = r.pending[:n*2]
+		hex.Encode(r.pending, r.raw[:n])
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
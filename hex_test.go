@@ -0,0 +1,52 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewHexDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	expected := []byte("48656c6c6f20576f726c64")
+
+	buf, err := ioutil.ReadAll(doppelgangerreader.NewHexDoppelganger(factory))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %s, but got %s", expected, buf)
+	}
+
+	// read with odd buffer sizes
+	var out bytes.Buffer
+	reader := doppelgangerreader.NewHexDoppelganger(factory)
+	p := make([]byte, 3)
+	for {
+		n, err := reader.Read(p)
+		out.Write(p[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+	if !bytes.Equal(expected, out.Bytes()) {
+		t.Fatalf("expected %s, but got %s", expected, out.Bytes())
+	}
+
+	buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
@@ -0,0 +1,69 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ErrInvalidJSON will be reported by a json validating doppelganger if the stream is not valid json
+var ErrInvalidJSON = errors.New("invalid json")
+
+// NewJSONValidatingDoppelganger creates a new reader that acts like the original reader
+// but only delivers complete and valid json values.
+// The bytes of the stream are delivered unchanged (including the whitespace between the values)
+// once the value they belong to has been validated.
+// If the stream contains invalid json Read returns ErrInvalidJSON.
+func NewJSONValidatingDoppelganger(factory DoppelgangerFactory) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	r := &jsonValidatingReader{
+		Closer: reader,
+	}
+	r.decoder = json.NewDecoder(io.TeeReader(reader, &r.raw))
+	return r
+}
+
+type jsonValidatingReader struct {
+	io.Closer
+	decoder *json.Decoder
+	// raw holds the bytes the decoder read but that have not been validated yet
+	raw bytes.Buffer
+	// validated is the offset in the stream up to which the bytes have been moved from raw to pending
+	validated int64
+	// pending holds the validated bytes that were not delivered yet
+	pending []byte
+	err     error
+}
+
+func (r *jsonValidatingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var value json.RawMessage
+		if err := r.decoder.Decode(&value); err != nil {
+			var syntaxError *json.SyntaxError
+			if errors.As(err, &syntaxError) || err == io.ErrUnexpectedEOF {
+				err = ErrInvalidJSON
+			}
+			if err == io.EOF {
+				// only whitespace is left after the last value
+				r.pending = r.raw.Bytes()
+			}
+			r.err = err
+			continue
+		}
+		if !json.Valid(value) {
+			r.err = ErrInvalidJSON
+			continue
+		}
+		// deliver the original bytes of the value and the whitespace in front of it
+		offset := r.decoder.InputOffset()
+		r.pending = append(r.pending[:0], r.raw.Next(int(offset-r.validated))...)
+		r.validated = offset
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewJSONValidatingDoppelganger(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		payload := []byte(`{"Hello":"World"}`)
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		buf, err := ioutil.ReadAll(doppelgangerreader.NewJSONValidatingDoppelganger(factory))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	t.Run("multiple values", func(t *testing.T) {
+		for _, payload := range []string{
+			"1 2",
+			"{\"a\":1}\n",
+			" \t[1, 2]\n{\"a\": \"b\"}  \"c\"\n\n",
+		} {
+			factory := doppelgangerreader.NewFactory(bytes.NewBufferString(payload))
+			buf, err := ioutil.ReadAll(doppelgangerreader.NewJSONValidatingDoppelganger(factory))
+			factory.Close()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if string(buf) != payload {
+				t.Fatalf("expected %q, but got %q", payload, buf)
+			}
+		}
+	})
+
+	t.Run("invalid value after a valid one", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewBufferString(`{"a":1} {"b":`))
+		defer factory.Close()
+
+		buf, err := ioutil.ReadAll(doppelgangerreader.NewJSONValidatingDoppelganger(factory))
+		if err != doppelgangerreader.ErrInvalidJSON {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrInvalidJSON, err)
+		}
+		if string(buf) != `{"a":1}` {
+			t.Fatalf("expected %q, but got %q", `{"a":1}`, buf)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		payload := []byte(`{"Hello":World}`)
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+
+		_, err := ioutil.ReadAll(doppelgangerreader.NewJSONValidatingDoppelganger(factory))
+		if err != doppelgangerreader.ErrInvalidJSON {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrInvalidJSON, err)
+		}
+
+		// other doppelgangers are not affected
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactory(bytes.NewBufferString(`{"Hello":`))
+		defer factory.Close()
+
+		_, err := ioutil.ReadAll(doppelgangerreader.NewJSONValidatingDoppelganger(factory))
+		if err != doppelgangerreader.ErrInvalidJSON {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrInvalidJSON, err)
+		}
+	})
+}
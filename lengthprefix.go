@@ -0,0 +1,55 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// NewLengthPrefixedDoppelganger creates a new reader that acts like the original reader
+// but prepends the total length of the stream as a big endian integer of headerSize (4 or 8) bytes.
+// To determine the length the source will be read until the end.
+// Other doppelgangers will not see the prefix.
+func NewLengthPrefixedDoppelganger(factory DoppelgangerFactory, headerSize int) (io.ReadCloser, error) {
+	if headerSize != 4 && headerSize != 8 {
+		return nil, errors.New("header size must be 4 or 8")
+	}
+	reader, err := newDoppelgangerErr(factory)
+	if err != nil {
+		return nil, err
+	}
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		reader.Close()
+		return nil, errors.New("reader is not seekable")
+	}
+
+	// seeking to the end drains the source
+	length, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	if headerSize == 4 {
+		if length > math.MaxUint32 {
+			reader.Close()
+			return nil, errors.New("stream is too long for a 4 byte header")
+		}
+		binary.BigEndian.PutUint32(header, uint32(length))
+	} else {
+		binary.BigEndian.PutUint64(header, uint64(length))
+	}
+
+	return &wrappedReader{
+		Reader: io.MultiReader(bytes.NewReader(header), reader),
+		Closer: reader,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewLengthPrefixedDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+
+	for _, test := range []struct {
+		HeaderSize int
+		Header     []byte
+	}{
+		{4, []byte{0, 0, 0, 11}},
+		{8, []byte{0, 0, 0, 0, 0, 0, 0, 11}},
+	} {
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+
+		reader, err := doppelgangerreader.NewLengthPrefixedDoppelganger(factory, test.HeaderSize)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		expected := append(test.Header, payload...)
+		if !bytes.Equal(expected, buf) {
+			t.Fatalf("expected %v, but got %v", expected, buf)
+		}
+
+		buf, err = ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+		factory.Close()
+	}
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+	if _, err := doppelgangerreader.NewLengthPrefixedDoppelganger(factory, 2); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,49 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// NewDoppelgangerLimited creates a new reader that acts like the original reader
+// but returns io.EOF after n bytes, like io.LimitReader.
+// Reads never consume more than the remaining n bytes and the reader closes itself once the limit is reached,
+// so it does not hold back the buffer of the factory.
+func NewDoppelgangerLimited(factory DoppelgangerFactory, n int64) io.ReadCloser {
+	return &limitedReader{
+		reader:    factory.NewDoppelganger(),
+		remaining: n,
+	}
+}
+
+type limitedReader struct {
+	reader    io.ReadCloser
+	remaining int64
+	closed    bool
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining <= 0 && err == nil {
+		err = r.Close()
+	}
+	return n, err
+}
+
+func (r *limitedReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.remaining = 0
+	return r.reader.Close()
+}
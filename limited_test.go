@@ -0,0 +1,51 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewDoppelgangerLimited(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewDoppelgangerLimited(factory, 5)
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[:5], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:5], buf)
+	}
+	// the reader closed itself
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+	if err = reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// closing early discards the remaining budget
+	reader = doppelgangerreader.NewDoppelgangerLimited(factory, 100)
+	readAtLeast(t, reader, 2)
+	if err = reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err = reader.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+
+	// a limit beyond the end of the stream
+	buf, err = ioutil.ReadAll(doppelgangerreader.NewDoppelgangerLimited(factory, 100))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
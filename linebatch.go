@@ -0,0 +1,74 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"io"
+)
+
+// NewLineBatchDoppelganger creates a new reader that acts like the original reader
+// and calls fn every time batchSize newline delimited lines (without the newline) have been consumed.
+// When Read returns io.EOF the remaining lines (including an unterminated last line) are passed to fn.
+// A batchSize below 1 is treated as 1.
+func NewLineBatchDoppelganger(factory DoppelgangerFactory, batchSize int, fn func(batch []string)) io.ReadCloser {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	reader := factory.NewDoppelganger()
+	return &lineBatchReader{
+		Reader:    reader,
+		Closer:    reader,
+		batchSize: batchSize,
+		fn:        fn,
+	}
+}
+
+type lineBatchReader struct {
+	io.Reader
+	io.Closer
+	batchSize int
+	fn        func(batch []string)
+	// line holds the consumed bytes of a line that has not been terminated yet
+	line    []byte
+	batch   []string
+	flushed bool
+}
+
+func (r *lineBatchReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.scan(p[:n])
+	}
+	if err == io.EOF && !r.flushed {
+		r.flushed = true
+		if len(r.line) > 0 {
+			r.batch = append(r.batch, string(r.line))
+			r.line = nil
+		}
+		if len(r.batch) > 0 {
+			r.flush()
+		}
+	}
+	return n, err
+}
+
+func (r *lineBatchReader) scan(p []byte) {
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.line = append(r.line, p...)
+			return
+		}
+		r.batch = append(r.batch, string(append(r.line, p[:i]...)))
+		r.line = r.line[:0]
+		p = p[i+1:]
+		if len(r.batch) == r.batchSize {
+			r.flush()
+		}
+	}
+}
+
+func (r *lineBatchReader) flush() {
+	batch := r.batch
+	r.batch = nil
+	r.fn(batch)
+}
@@ -0,0 +1,37 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewLineBatchDoppelganger(t *testing.T) {
+	payload := []byte("one\ntwo\nthree\nfour\nfive")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	var batches [][]string
+	reader := doppelgangerreader.NewLineBatchDoppelganger(factory, 2, func(batch []string) {
+		batches = append(batches, batch)
+	})
+	defer reader.Close()
+
+	// read byte by byte so lines span over multiple reads
+	buf, err := ioutil.ReadAll(iotest.OneByteReader(reader))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	expected := [][]string{{"one", "two"}, {"three", "four"}, {"five"}}
+	if !reflect.DeepEqual(expected, batches) {
+		t.Fatalf("expected %v, but got %v", expected, batches)
+	}
+}
@@ -0,0 +1,39 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// MessageDoppelganger is a doppelganger that reads the stream as fixed size messages
+type MessageDoppelganger interface {
+	io.ReadCloser
+	// ReadMessage returns the next message, io.EOF if there are no more messages
+	// or io.ErrUnexpectedEOF if the stream ends inside a message
+	ReadMessage() ([]byte, error)
+}
+
+// NewMessageDoppelganger creates a new reader that acts like the original reader
+// and reads the stream as messages of exactly messageSize bytes, it panics if messageSize is not positive.
+// ReadMessage waits until a whole message is available.
+func NewMessageDoppelganger(factory DoppelgangerFactory, messageSize int) MessageDoppelganger {
+	if messageSize <= 0 {
+		panic("message size must be positive")
+	}
+	return &messageReader{
+		ReadCloser:  factory.NewDoppelganger(),
+		messageSize: messageSize,
+	}
+}
+
+type messageReader struct {
+	io.ReadCloser
+	messageSize int
+}
+
+func (r *messageReader) ReadMessage() ([]byte, error) {
+	message := make([]byte, r.messageSize)
+	if _, err := io.ReadFull(r.ReadCloser, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,36 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewMessageDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewMessageDoppelganger(factory, 5)
+	defer reader.Close()
+
+	for _, expected := range [][]byte{payload[:5], payload[5:10]} {
+		message, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(expected, message) {
+			t.Fatalf("expected %v, but got %v", expected, message)
+		}
+	}
+
+	if _, err := reader.ReadMessage(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v, but got %v", io.ErrUnexpectedEOF, err)
+	}
+	if _, err := reader.ReadMessage(); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+}
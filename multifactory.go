@@ -0,0 +1,108 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrSourcesEnded will be reported by AppendSource if the stream of the MultiFactory already ended
+var ErrSourcesEnded = errors.New("sources already ended")
+
+// MultiFactory is a DoppelgangerFactory for the concatenation of multiple readers, see NewMultiFactory
+type MultiFactory interface {
+	DoppelgangerFactory
+	AppendSource(r io.Reader) error
+}
+
+// NewMultiFactory creates a new DoppelgangerFactory for the concatenation of the specified readers,
+// like io.MultiReader the readers are read one after another. Further readers can be added with AppendSource.
+func NewMultiFactory(sources ...io.Reader) MultiFactory {
+	s := newMultiSource(sources...)
+	return &multiFactory{
+		doppelgangerFactory: &doppelgangerFactory{
+			source: s,
+		},
+		sources: s,
+	}
+}
+
+type multiFactory struct {
+	*doppelgangerFactory
+	sourcesMu sync.Mutex
+	sources   *multiSource
+}
+
+// AppendSource adds r to the end of the stream, it returns ErrSourcesEnded if the readers
+// that have been added before already reached io.EOF and ErrFactoryClosed if the factory has been closed.
+func (factory *multiFactory) AppendSource(r io.Reader) error {
+	factory.doppelgangerFactory.mu.Lock()
+	closed := factory.doppelgangerFactory.closed
+	factory.doppelgangerFactory.mu.Unlock()
+	if closed {
+		return ErrFactoryClosed
+	}
+	factory.sourcesMu.Lock()
+	defer factory.sourcesMu.Unlock()
+	return factory.sources.append(r)
+}
+
+// Reset works like DoppelgangerFactory.Reset, further readers can be added with AppendSource afterwards.
+func (factory *multiFactory) Reset(r io.Reader) error {
+	s := newMultiSource(r)
+	if err := factory.doppelgangerFactory.Reset(s); err != nil {
+		return err
+	}
+	factory.sourcesMu.Lock()
+	factory.sources = s
+	factory.sourcesMu.Unlock()
+	return nil
+}
+
+// multiSource reads the readers one after another, readers can be appended until it reported io.EOF.
+type multiSource struct {
+	mu      sync.Mutex
+	readers []io.Reader
+	ended   bool
+}
+
+func newMultiSource(readers ...io.Reader) *multiSource {
+	return &multiSource{
+		readers: append([]io.Reader(nil), readers...),
+	}
+}
+
+func (s *multiSource) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if len(s.readers) == 0 {
+			s.ended = true
+			s.mu.Unlock()
+			return 0, io.EOF
+		}
+		r := s.readers[0]
+		s.mu.Unlock()
+
+		// do not block append while reading
+		n, err := r.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		s.mu.Lock()
+		s.readers = s.readers[1:]
+		s.mu.Unlock()
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (s *multiSource) append(r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return ErrSourcesEnded
+	}
+	s.readers = append(s.readers, r)
+	return nil
+}
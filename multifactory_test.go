@@ -0,0 +1,71 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewMultiFactory(t *testing.T) {
+	factory := doppelgangerreader.NewMultiFactory(bytes.NewBufferString("Hello"), bytes.NewBufferString(" "))
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	defer reader1.Close()
+	if buf := read(t, reader1, 5); string(buf) != "Hello" {
+		t.Fatalf("expected %q, but got %q", "Hello", buf)
+	}
+
+	if err := factory.AppendSource(bytes.NewBufferString("World")); err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := ioutil.ReadAll(reader1); string(buf) != " World" {
+		t.Fatalf("expected %q, but got %q", " World", buf)
+	}
+
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+	if buf, _ := ioutil.ReadAll(reader2); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+	if !doppelgangerreader.IsDoppelgangerOf(reader2, factory) {
+		t.Fatal("expected reader2 to be a doppelganger of factory")
+	}
+
+	if err := factory.AppendSource(bytes.NewBufferString("!")); err != doppelgangerreader.ErrSourcesEnded {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrSourcesEnded, err)
+	}
+}
+
+func TestMultiFactoryReset(t *testing.T) {
+	factory := doppelgangerreader.NewMultiFactory()
+	defer factory.Close()
+
+	if err := factory.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.AppendSource(bytes.NewBufferString("World")); err != doppelgangerreader.ErrSourcesEnded {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrSourcesEnded, err)
+	}
+
+	if err := factory.Reset(bytes.NewBufferString("Hello ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.AppendSource(bytes.NewBufferString("World")); err != nil {
+		t.Fatal(err)
+	}
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	if buf, _ := ioutil.ReadAll(reader); string(buf) != "Hello World" {
+		t.Fatalf("expected %q, but got %q", "Hello World", buf)
+	}
+
+	if err := factory.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.AppendSource(bytes.NewBufferString("!")); err != doppelgangerreader.ErrFactoryClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryClosed, err)
+	}
+}
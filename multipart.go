@@ -0,0 +1,33 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"mime/multipart"
+)
+
+// MultipartDoppelganger is a doppelganger that reads the stream as a multipart body
+type MultipartDoppelganger interface {
+	io.ReadCloser
+	// ReadPart returns the next part of the multipart body or io.EOF if there are no more parts
+	ReadPart() (*multipart.Part, error)
+}
+
+// NewMultipartDoppelganger creates a new reader that acts like the original reader
+// and reads the stream as a multipart body with the specified boundary.
+// Use either ReadPart or Read, mixing both will corrupt the multipart parsing.
+func NewMultipartDoppelganger(factory DoppelgangerFactory, boundary string) MultipartDoppelganger {
+	reader := factory.NewDoppelganger()
+	return &multipartReader{
+		ReadCloser: reader,
+		reader:     multipart.NewReader(reader, boundary),
+	}
+}
+
+type multipartReader struct {
+	io.ReadCloser
+	reader *multipart.Reader
+}
+
+func (r *multipartReader) ReadPart() (*multipart.Part, error) {
+	return r.reader.NextPart()
+}
@@ -0,0 +1,67 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewMultipartDoppelganger(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.WriteField("key1", "val1"); err != nil {
+		t.Fatalf("WriteField: %v", err)
+	}
+	if err := w.WriteField("key2", "val2"); err != nil {
+		t.Fatalf("WriteField: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	payload := buf.Bytes()
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewMultipartDoppelganger(factory, w.Boundary())
+	defer reader.Close()
+
+	for _, expected := range []struct {
+		Name  string
+		Value string
+	}{
+		{"key1", "val1"},
+		{"key2", "val2"},
+	} {
+		part, err := reader.ReadPart()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if part.FormName() != expected.Name {
+			t.Fatalf("expected %q, but got %q", expected.Name, part.FormName())
+		}
+		value, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(value) != expected.Value {
+			t.Fatalf("expected %q, but got %q", expected.Value, value)
+		}
+	}
+	if _, err := reader.ReadPart(); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+
+	// other doppelgangers see the raw body
+	raw, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, raw) {
+		t.Fatalf("expected %v, but got %v", payload, raw)
+	}
+}
@@ -0,0 +1,60 @@
+package doppelgangerreader
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// factoryMutex is the lock of a factory, it does nothing if the factory has been created WithNoLock.
+type factoryMutex struct {
+	mu     sync.RWMutex
+	noLock bool
+	// held is set while the lock of a WithNoLock factory is held, it is only used if noLockChecks is set
+	held int32
+}
+
+func (m *factoryMutex) Lock() {
+	if m.noLock {
+		m.acquire()
+		return
+	}
+	m.mu.Lock()
+}
+
+func (m *factoryMutex) Unlock() {
+	if m.noLock {
+		m.release()
+		return
+	}
+	m.mu.Unlock()
+}
+
+func (m *factoryMutex) RLock() {
+	if m.noLock {
+		m.acquire()
+		return
+	}
+	m.mu.RLock()
+}
+
+func (m *factoryMutex) RUnlock() {
+	if m.noLock {
+		m.release()
+		return
+	}
+	m.mu.RUnlock()
+}
+
+// acquire panics if another goroutine uses the factory at the same time, the check is
+// only enabled in race (-race) and debug (-tags debug) builds.
+func (m *factoryMutex) acquire() {
+	if noLockChecks && !atomic.CompareAndSwapInt32(&m.held, 0, 1) {
+		panic("doppelgangerreader: concurrent use of a factory created with WithNoLock")
+	}
+}
+
+func (m *factoryMutex) release() {
+	if noLockChecks {
+		atomic.StoreInt32(&m.held, 0)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build debug || race
+
+package doppelgangerreader
+
+// noLockChecks enables the detection of concurrent use of WithNoLock factories
+const noLockChecks = true
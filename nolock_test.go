@@ -0,0 +1,41 @@
+//go:build debug || race
+
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+type writerFunc func(p []byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) {
+	return fn(p)
+}
+
+func TestWithNoLockDetectsConcurrentUse(t *testing.T) {
+	var factory doppelgangerreader.DoppelgangerFactory
+	// the tee is called while the factory is in use, accessing the factory from there must be detected
+	tee := writerFunc(func(p []byte) (int, error) {
+		factory.BufferedBytes()
+		return len(p), nil
+	})
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewBufferString("Hello World"),
+		doppelgangerreader.WithNoLock(),
+		doppelgangerreader.WithTee(tee),
+	)
+	reader := factory.NewDoppelganger()
+
+	defer func() {
+		r := recover()
+		if msg, ok := r.(string); !ok || !strings.Contains(msg, "WithNoLock") {
+			t.Fatalf("expected a panic about WithNoLock, but got %v", r)
+		}
+	}()
+	_, _ = reader.Read(make([]byte, 5))
+	t.Fatal("expected a panic")
+}
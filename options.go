@@ -0,0 +1,330 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"reflect"
+	"time"
+)
+
+// Option configures a DoppelgangerFactory created with NewFactoryWithOptions
+type Option func(*factoryConfig)
+
+type factoryConfig struct {
+	maxBufferSize      int64
+	bufferFullBehavior BufferFullBehavior
+	bufferEviction     bool
+	hasher             hash.Hash
+	chunkSize          int
+	// maxDoppelgangers limits the number of active readers (0 disables the limit)
+	maxDoppelgangers       int
+	maxDoppelgangersPolicy MaxDoppelgangersPolicy
+	tees                   []io.Writer
+	progressFunc           func(bufferedBytes int64)
+	progressInterval       time.Duration
+	bufferLimit            int64
+	bufferLimitBehavior    BufferLimitBehavior
+	closeSource            bool
+	readRate               int64
+	onSourceEOF            func(totalBytes int64)
+	onSourceError          func(err error)
+	subscribeDropPolicy    SubscribeDropPolicy
+	decompression          string
+	prefetchConcurrency    int
+	noLock                 bool
+}
+
+// BufferFullBehavior controls what happens when the buffer limit set with WithMaxBufferSize is reached
+type BufferFullBehavior int
+
+const (
+	// BlockOnFull blocks the faster doppelgangers until the slower ones caught up
+	BlockOnFull BufferFullBehavior = iota
+	// ErrorOnFull lets the faster doppelgangers fail with ErrBufferFull
+	ErrorOnFull
+)
+
+// ErrBufferFull will be reported if the buffer limit is reached and ErrorOnFull is used
+var ErrBufferFull = errors.New("buffer is full")
+
+// BufferLimitBehavior controls what happens when the limit set with WithBufferLimit is reached
+type BufferLimitBehavior int
+
+const (
+	// BlockOnExceed blocks the doppelgangers until buffered data has been evicted
+	BlockOnExceed BufferLimitBehavior = iota
+	// ErrorOnExceed lets the doppelgangers that need new data fail with ErrBufferLimitExceeded
+	ErrorOnExceed
+)
+
+// ErrBufferLimitExceeded will be reported if the limit of WithBufferLimit is reached and ErrorOnExceed is used
+var ErrBufferLimitExceeded = errors.New("buffer limit exceeded")
+
+// IsBufferLimitError returns true if the specified error is (or wraps) ErrBufferLimitExceeded or ErrBufferFull
+func IsBufferLimitError(err error) bool {
+	return errors.Is(err, ErrBufferLimitExceeded) || errors.Is(err, ErrBufferFull)
+}
+
+// MaxDoppelgangersPolicy controls what NewDoppelganger does when the limit set with WithMaxDoppelgangers is reached
+type MaxDoppelgangersPolicy int
+
+const (
+	// PanicOnMaxDoppelgangers lets NewDoppelganger panic,
+	// the constructors with an error return report ErrTooManyDoppelgangers instead
+	PanicOnMaxDoppelgangers MaxDoppelgangersPolicy = iota
+	// BlockOnMaxDoppelgangers lets NewDoppelganger block until an active doppelganger has been closed
+	BlockOnMaxDoppelgangers
+)
+
+// SubscribeDropPolicy controls what happens when a channel registered with Subscribe is full
+type SubscribeDropPolicy int
+
+const (
+	// BlockOnFullSubscriber blocks the source reads until the subscriber received the chunk
+	BlockOnFullSubscriber SubscribeDropPolicy = iota
+	// DropOnFullSubscriber drops the chunk for the subscriber
+	DropOnFullSubscriber
+)
+
+// ErrTooManyDoppelgangers will be reported by NewDoppelgangerErr (and the other constructors with an error return)
+// if the limit set with WithMaxDoppelgangers is reached
+var ErrTooManyDoppelgangers = errors.New("too many doppelgangers")
+
+// NewFactoryWithOptions creates a new DoppelgangerFactory with the original reader specified
+// and applies the specified options.
+// Without options (or with options that only select the default behavior, like DefaultOptions) it behaves
+// like NewFactory, if the reader is already a Doppelganger the original factory will be used.
+// With other options a new factory is created, because the options cannot be applied to the buffer
+// of the original factory.
+func NewFactoryWithOptions(readerToMimic io.Reader, opts ...Option) DoppelgangerFactory {
+	var config factoryConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.decompression == "identity" {
+		config.decompression = ""
+	}
+	// options that only describe the default behavior (e.g. DefaultOptions) nest like NewFactory
+	if reflect.DeepEqual(config, factoryConfig{}) {
+		if parent := GetFactory(readerToMimic); parent != nil {
+			return &nestedDoppelgangerFactory{
+				parent: parent,
+			}
+		}
+	}
+	factory := &doppelgangerFactory{
+		config: config,
+		source: readerToMimic,
+	}
+	factory.buffer.chunkSize = factory.config.chunkSize
+	factory.mu.noLock = factory.config.noLock
+	factory.tees = factory.config.tees
+	factory.source = decompress(readerToMimic, factory.config.decompression)
+	factory.limitSource()
+	return factory
+}
+
+// DefaultOptions returns the options that describe the default behavior of a factory,
+// callers can append their own options to change single settings:
+//
+//	NewFactoryWithOptions(r, append(DefaultOptions(), WithBufferEviction())...)
+func DefaultOptions() []Option {
+	return []Option{
+		WithBufferFullBehavior(BlockOnFull),
+		WithBufferLimitBehavior(BlockOnExceed),
+		WithMaxDoppelgangersPolicy(PanicOnMaxDoppelgangers),
+		WithSubscribeDropPolicy(BlockOnFullSubscriber),
+		WithDecompression("identity"),
+	}
+}
+
+// WithMaxBufferSize limits the number of bytes that will be buffered for the slowest doppelganger.
+// If the limit is reached the faster doppelgangers have to wait (or fail, see WithBufferFullBehavior)
+// until the slower ones caught up. No data will be dropped. (0 disables the limit)
+// Notice that a doppelganger that is not read and not closed will block the faster ones forever.
+func WithMaxBufferSize(n int64) Option {
+	return func(config *factoryConfig) {
+		config.maxBufferSize = n
+	}
+}
+
+// WithBufferFullBehavior sets the behavior when the limit of WithMaxBufferSize is reached,
+// defaults to BlockOnFull
+func WithBufferFullBehavior(behavior BufferFullBehavior) Option {
+	return func(config *factoryConfig) {
+		config.bufferFullBehavior = behavior
+	}
+}
+
+// WithBufferEviction releases buffered data as soon as it has been read by all active doppelgangers.
+// New doppelgangers start at the oldest data that is still buffered,
+// seeking (or reading) before that position fails with ErrEvicted.
+func WithBufferEviction() Option {
+	return func(config *factoryConfig) {
+		config.bufferEviction = true
+	}
+}
+
+// WithHasher writes every byte that is read from the source into h (exactly once),
+// use SumHash to get the hash of the bytes read so far.
+func WithHasher(h hash.Hash) Option {
+	return func(config *factoryConfig) {
+		config.hasher = h
+	}
+}
+
+// WithTee writes every byte that is read from the source into w (exactly once), as soon as it has been read.
+// Errors of w do not affect the doppelgangers, the first one is reported by TeeError
+// and w will not receive any further data. Multiple WithTee options write to multiple writers.
+func WithTee(w io.Writer) Option {
+	return func(config *factoryConfig) {
+		config.tees = append(config.tees, w)
+	}
+}
+
+// WithChunkSize sets the size of the chunks the buffer is made of (chunks are reused across factories)
+// and the number of bytes every Read on the source requests. Panics if size is not positive.
+// Without this option the buffer uses chunks of DefaultChunkSize and reads on the source request
+// as many bytes as the doppelgangers ask for.
+func WithChunkSize(size int) Option {
+	if size <= 0 {
+		panic("chunk size must be positive")
+	}
+	return func(config *factoryConfig) {
+		config.chunkSize = size
+	}
+}
+
+// WithMaxDoppelgangers limits the number of doppelgangers that can be active at the same time,
+// closing a doppelganger makes its slot available again. (0 disables the limit)
+// NewDoppelgangerErr reports ErrTooManyDoppelgangers if the limit is reached,
+// what the other constructors do is controlled by WithMaxDoppelgangersPolicy.
+func WithMaxDoppelgangers(n int) Option {
+	return func(config *factoryConfig) {
+		config.maxDoppelgangers = n
+	}
+}
+
+// WithMaxDoppelgangersPolicy sets the behavior of NewDoppelganger (and the other constructors)
+// when the limit of WithMaxDoppelgangers is reached, defaults to PanicOnMaxDoppelgangers
+func WithMaxDoppelgangersPolicy(policy MaxDoppelgangersPolicy) Option {
+	return func(config *factoryConfig) {
+		config.maxDoppelgangersPolicy = policy
+	}
+}
+
+// WithProgressFunc calls fn with the total number of bytes read from the source every time
+// new data has been read into the buffer and once the source ended, see WithProgressInterval.
+// fn is called by the goroutine reading the source while the factory is locked,
+// so it must not block and must not call methods of the factory or its doppelgangers.
+func WithProgressFunc(fn func(bufferedBytes int64)) Option {
+	return func(config *factoryConfig) {
+		config.progressFunc = fn
+	}
+}
+
+// WithProgressInterval limits the calls of the WithProgressFunc function to one per interval,
+// the end of the source is always reported.
+func WithProgressInterval(d time.Duration) Option {
+	return func(config *factoryConfig) {
+		config.progressInterval = d
+	}
+}
+
+// WithBufferLimit caps the number of bytes the buffer holds (see BufferedBytes), regardless of how many
+// bytes the doppelgangers still have to read. Buffered data stays readable, but no more data will be read
+// from the source until data has been evicted (see WithBufferEviction and WithBufferLimitBehavior).
+// Without eviction the limit caps the total number of bytes read from the source. (0 disables the limit)
+func WithBufferLimit(n int64) Option {
+	return func(config *factoryConfig) {
+		config.bufferLimit = n
+	}
+}
+
+// WithBufferLimitBehavior sets the behavior when the limit of WithBufferLimit is reached,
+// defaults to BlockOnExceed
+func WithBufferLimitBehavior(behavior BufferLimitBehavior) Option {
+	return func(config *factoryConfig) {
+		config.bufferLimitBehavior = behavior
+	}
+}
+
+// WithCloseSource closes the original reader (if it implements io.Closer) when the factory is closed,
+// Close returns the error of the original reader. The original reader will be closed only once.
+func WithCloseSource() Option {
+	return func(config *factoryConfig) {
+		config.closeSource = true
+	}
+}
+
+// WithReadRate limits the rate the source is read with to bytesPerSec on average, the limit is shared
+// by all doppelgangers. Bursts of up to one second worth of data are allowed, afterwards the reads
+// on the source are delayed. Closing the factory stops the delay. (0 disables the limit)
+func WithReadRate(bytesPerSec int64) Option {
+	return func(config *factoryConfig) {
+		config.readRate = bytesPerSec
+	}
+}
+
+// WithOnSourceEOF calls fn once the source reported io.EOF with the number of bytes read from the source
+// (excluding the prefix of NewFactoryWithData). fn is called by the goroutine that read io.EOF,
+// the factory is not locked so fn may use the factory. The doppelgangers might see io.EOF before fn is called.
+func WithOnSourceEOF(fn func(totalBytes int64)) Option {
+	return func(config *factoryConfig) {
+		config.onSourceEOF = fn
+	}
+}
+
+// WithOnSourceError calls fn once the source reported an error other than io.EOF,
+// see WithOnSourceEOF
+func WithOnSourceError(fn func(err error)) Option {
+	return func(config *factoryConfig) {
+		config.onSourceError = fn
+	}
+}
+
+// WithSubscribeDropPolicy sets the behavior when a channel registered with Subscribe is full,
+// defaults to BlockOnFullSubscriber
+func WithSubscribeDropPolicy(policy SubscribeDropPolicy) Option {
+	return func(config *factoryConfig) {
+		config.subscribeDropPolicy = policy
+	}
+}
+
+// WithDecompression decompresses the source before it is buffered, so all doppelgangers see the
+// uncompressed data. Supported formats are "gzip", "deflate" (the zlib format, like the HTTP content encoding)
+// and "identity" (no decompression). If the source is not compressed in the specified format
+// (or the format is not supported) the first read fails with ErrDecompressionFailed.
+func WithDecompression(format string) Option {
+	return func(config *factoryConfig) {
+		config.decompression = format
+	}
+}
+
+// WithPrefetchConcurrency lets Prefetch read the source with n concurrent ReadAt calls on consecutive,
+// non-overlapping ranges of the requested read size, the data is buffered in the order of the source.
+// The original reader must implement io.ReaderAt and its offset 0 must be the start of the stream.
+// Once Prefetch started, all reads on the source use ReadAt. (0 or 1 reads the source sequentially)
+func WithPrefetchConcurrency(n int) Option {
+	return func(config *factoryConfig) {
+		config.prefetchConcurrency = n
+	}
+}
+
+// WithNoLock disables the internal locking of the factory for pipelines that use the factory
+// and all of its doppelgangers from a single goroutine.
+// Like with a bytes.Buffer, using such a factory (or its doppelgangers) from multiple goroutines
+// at the same time is undefined behavior. This includes the goroutines the factory starts itself,
+// e.g. for read deadlines, Prefetch, heartbeat or async close doppelgangers.
+// The source is read on the goroutine of the doppelganger, a pending read cannot be interrupted
+// by a read deadline or a context.
+// Because there is no other goroutine that could make room, the blocking policies behave like their
+// error counterparts: BlockOnFull like ErrorOnFull, BlockOnExceed like ErrorOnExceed and
+// BlockOnMaxDoppelgangers like PanicOnMaxDoppelgangers.
+// Race (-race) and debug (-tags debug) builds panic if they detect concurrent use.
+func WithNoLock() Option {
+	return func(config *factoryConfig) {
+		config.noLock = true
+	}
+}
@@ -0,0 +1,781 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestWithMaxBufferSize(t *testing.T) {
+	payload := []byte("Hello World")
+
+	t.Run("block", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithMaxBufferSize(4))
+		defer factory.Close()
+
+		fast := factory.NewDoppelganger()
+		slow := factory.NewDoppelganger()
+
+		done := make(chan []byte)
+		go func() {
+			buf, err := ioutil.ReadAll(fast)
+			if err != nil {
+				t.Errorf("expected no error, but got %v", err)
+			}
+			done <- buf
+		}()
+
+		// give the fast reader some time to fill the buffer
+		time.Sleep(time.Millisecond * 50)
+		if size := factory.BufferSize(); size != 4 {
+			t.Fatalf("expected 4, but got %d", size)
+		}
+
+		select {
+		case <-done:
+			t.Fatalf("expected fast reader to block")
+		default:
+		}
+
+		buf, err := ioutil.ReadAll(slow)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+		buf = <-done
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithMaxBufferSize(4),
+			doppelgangerreader.WithBufferFullBehavior(doppelgangerreader.ErrorOnFull),
+		)
+		defer factory.Close()
+
+		fast := factory.NewDoppelganger()
+		slow := factory.NewDoppelganger()
+
+		buf := readAtLeast(t, fast, 4)
+		if !bytes.Equal(payload[:4], buf) {
+			t.Fatalf("expected %v, but got %v", payload[:4], buf)
+		}
+		if _, err := fast.Read(buf); err != doppelgangerreader.ErrBufferFull {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferFull, err)
+		}
+
+		// after the slow reader caught up, the fast reader can continue
+		readAtLeast(t, slow, 4)
+		buf = readAtLeast(t, fast, 4)
+		if !bytes.Equal(payload[4:8], buf) {
+			t.Fatalf("expected %v, but got %v", payload[4:8], buf)
+		}
+		if _, err := fast.Read(buf); err != doppelgangerreader.ErrBufferFull {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferFull, err)
+		}
+	})
+}
+
+func TestWithBufferEviction(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(payload),
+		doppelgangerreader.WithBufferEviction(),
+		// evict in small steps
+		doppelgangerreader.WithChunkSize(2),
+	)
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+
+	readAtLeast(t, reader1, 8)
+	if n := factory.BufferedBytes(); n != 8 {
+		t.Fatalf("expected 8, but got %d", n)
+	}
+
+	// reader2 consumed the data too, so it can be evicted
+	readAtLeast(t, reader2, 6)
+	if n := factory.BufferedBytes(); n != 2 {
+		t.Fatalf("expected 2, but got %d", n)
+	}
+
+	if _, err := factory.NewDoppelgangerAt(0); err != doppelgangerreader.ErrEvicted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrEvicted, err)
+	}
+	if _, err := reader2.(io.Seeker).Seek(0, io.SeekStart); err != doppelgangerreader.ErrEvicted {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrEvicted, err)
+	}
+
+	// a new reader starts at the oldest available data
+	buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+
+	buf, err = ioutil.ReadAll(reader2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+
+	// closing the last reader that holds back the data evicts it
+	if err := reader1.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if n := factory.BufferedBytes(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+}
+
+func TestWithHasher(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithHasher(sha256.New()))
+	defer factory.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+
+	expected := sha256.Sum256(payload)
+	if sum := factory.SumHash(nil); !bytes.Equal(expected[:], sum) {
+		t.Fatalf("expected %x, but got %x", expected, sum)
+	}
+}
+
+// readSizeRecorder records the size of every Read call
+type readSizeRecorder struct {
+	io.Reader
+	sizes []int
+}
+
+func (r *readSizeRecorder) Read(p []byte) (int, error) {
+	r.sizes = append(r.sizes, len(p))
+	return r.Reader.Read(p)
+}
+
+func TestWithChunkSizeSourceReads(t *testing.T) {
+	for _, test := range []struct {
+		size    int
+		repeats int
+	}{
+		{size: 1, repeats: 100},
+		{size: 1024 * 1024, repeats: 300000},
+	} {
+		size := test.size
+		payload := bytes.Repeat([]byte("Hello World"), test.repeats)
+		source := &readSizeRecorder{Reader: bytes.NewReader(payload)}
+		factory := doppelgangerreader.NewFactoryWithOptions(source, doppelgangerreader.WithChunkSize(size))
+
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %d bytes, but got %d", len(payload), len(buf))
+		}
+		for _, n := range source.sizes {
+			if n != size {
+				t.Fatalf("expected %d, but got %d", size, n)
+			}
+		}
+		if expected := (len(payload)+size-1)/size + 1; len(source.sizes) != expected {
+			t.Fatalf("expected %d, but got %d", expected, len(source.sizes))
+		}
+		factory.Close()
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	doppelgangerreader.WithChunkSize(0)
+}
+
+func TestWithChunkSize(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(payload),
+		doppelgangerreader.WithChunkSize(3),
+		doppelgangerreader.WithBufferEviction(),
+	)
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+
+	// read across chunk boundaries
+	buf := readAtLeast(t, reader1, 7)
+	if !bytes.Equal(payload[:7], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:7], buf)
+	}
+
+	buf = make([]byte, 4)
+	n, err := factory.ReadAt(buf, 2)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[2:6], buf[:n]) {
+		t.Fatalf("expected %v, but got %v", payload[2:6], buf[:n])
+	}
+
+	// only whole chunks are evicted, the source has been read in chunks of 3 bytes up to offset 9
+	readAtLeast(t, reader2, 4)
+	if n := factory.BufferedBytes(); n != 6 {
+		t.Fatalf("expected 6, but got %d", n)
+	}
+
+	var out bytes.Buffer
+	if _, err := reader2.(io.WriterTo).WriteTo(&out); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[4:], out.Bytes()) {
+		t.Fatalf("expected %v, but got %v", payload[4:], out.Bytes())
+	}
+	rest, err := ioutil.ReadAll(reader1)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[7:], rest) {
+		t.Fatalf("expected %v, but got %v", payload[7:], rest)
+	}
+}
+
+func TestWithMaxDoppelgangers(t *testing.T) {
+	payload := []byte("Hello World")
+
+	t.Run("error", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithMaxDoppelgangers(2))
+		defer factory.Close()
+
+		d1, err := factory.NewDoppelgangerErr()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if _, err = factory.NewDoppelgangerErr(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if _, err = factory.NewDoppelgangerErr(); err != doppelgangerreader.ErrTooManyDoppelgangers {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrTooManyDoppelgangers, err)
+		}
+
+		// closing a doppelganger frees its slot
+		if err = d1.Close(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		d3, err := factory.NewDoppelgangerErr()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if buf, _ := ioutil.ReadAll(d3); !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithMaxDoppelgangers(1))
+		defer factory.Close()
+
+		factory.NewDoppelganger()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected a panic")
+			}
+		}()
+		factory.NewDoppelganger()
+	})
+
+	t.Run("constructors with error", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithMaxDoppelgangers(1))
+		defer factory.Close()
+
+		d1 := factory.NewDoppelganger()
+		defer d1.Close()
+		constructors := map[string]func() (io.ReadCloser, error){
+			"NewDoppelgangerAt":      func() (io.ReadCloser, error) { return factory.NewDoppelgangerAt(1) },
+			"NewNamedDoppelganger":   func() (io.ReadCloser, error) { return factory.NewNamedDoppelganger("name") },
+			"Tail":                   func() (io.ReadCloser, error) { return factory.Tail(1) },
+			"NewDoppelgangerSection": func() (io.ReadCloser, error) { return doppelgangerreader.NewDoppelgangerSection(factory, 1, 2) },
+			"NewZlibDoppelganger":    func() (io.ReadCloser, error) { return doppelgangerreader.NewZlibDoppelganger(factory, -1) },
+			"Fork": func() (io.ReadCloser, error) {
+				child, err := factory.Fork(1)
+				if err != nil {
+					return nil, err
+				}
+				return child.NewDoppelganger(), nil
+			},
+		}
+		for name, constructor := range constructors {
+			if _, err := constructor(); err != doppelgangerreader.ErrTooManyDoppelgangers {
+				t.Fatalf("%s: expected %v, but got %v", name, doppelgangerreader.ErrTooManyDoppelgangers, err)
+			}
+		}
+		if count := factory.ActiveDoppelgangerCount(); count != 1 {
+			t.Fatalf("expected %d, but got %d", 1, count)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithMaxDoppelgangers(1),
+			doppelgangerreader.WithMaxDoppelgangersPolicy(doppelgangerreader.BlockOnMaxDoppelgangers),
+		)
+		defer factory.Close()
+
+		d1 := factory.NewDoppelganger()
+		done := make(chan io.ReadCloser)
+		go func() {
+			done <- factory.NewDoppelganger()
+		}()
+
+		select {
+		case <-done:
+			t.Fatalf("expected NewDoppelganger to block")
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		if err := d1.Close(); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		d2 := <-done
+		if buf, _ := ioutil.ReadAll(d2); !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+}
+
+func TestWithTee(t *testing.T) {
+	payload := []byte("Hello World")
+	var tee1, tee2 bytes.Buffer
+	failing := failWriter{err: errors.New("audit log is down")}
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(payload),
+		doppelgangerreader.WithTee(&tee1),
+		doppelgangerreader.WithTee(failing),
+		doppelgangerreader.WithTee(&tee2),
+	)
+	defer factory.Close()
+
+	for i := 0; i < 2; i++ {
+		reader := factory.NewDoppelganger()
+		buf, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+		reader.Close()
+	}
+
+	// every byte has been written only once
+	if !bytes.Equal(payload, tee1.Bytes()) {
+		t.Fatalf("expected %v, but got %v", payload, tee1.Bytes())
+	}
+	if !bytes.Equal(payload, tee2.Bytes()) {
+		t.Fatalf("expected %v, but got %v", payload, tee2.Bytes())
+	}
+	if err := factory.TeeError(); err != failing.err {
+		t.Fatalf("expected %v, but got %v", failing.err, err)
+	}
+}
+
+func TestWithProgressFunc(t *testing.T) {
+	payload := []byte("Hello World")
+
+	t.Run("every read", func(t *testing.T) {
+		var calls []int64
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			iotest.OneByteReader(bytes.NewReader(payload)),
+			doppelgangerreader.WithProgressFunc(func(bufferedBytes int64) {
+				calls = append(calls, bufferedBytes)
+			}),
+		)
+		defer factory.Close()
+
+		if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		// one call per byte and one for the end of the source
+		if len(calls) != len(payload)+1 {
+			t.Fatalf("expected %d, but got %d", len(payload)+1, len(calls))
+		}
+		for i, n := range calls[:len(payload)] {
+			if n != int64(i+1) {
+				t.Fatalf("expected %d, but got %d", i+1, n)
+			}
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		var calls []int64
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			iotest.OneByteReader(bytes.NewReader(payload)),
+			doppelgangerreader.WithProgressFunc(func(bufferedBytes int64) {
+				calls = append(calls, bufferedBytes)
+			}),
+			doppelgangerreader.WithProgressInterval(time.Hour),
+		)
+		defer factory.Close()
+
+		if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		expected := []int64{1, int64(len(payload))}
+		if !reflect.DeepEqual(expected, calls) {
+			t.Fatalf("expected %v, but got %v", expected, calls)
+		}
+	})
+}
+
+func TestWithBufferLimit(t *testing.T) {
+	payload := []byte("Hello World")
+
+	t.Run("error", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithBufferLimit(5),
+			doppelgangerreader.WithBufferLimitBehavior(doppelgangerreader.ErrorOnExceed),
+		)
+		defer factory.Close()
+
+		reader1 := factory.NewDoppelganger()
+		buf := readAtLeast(t, reader1, 5)
+		if !bytes.Equal(payload[:5], buf) {
+			t.Fatalf("expected %v, but got %v", payload[:5], buf)
+		}
+		_, err := reader1.Read(make([]byte, 1))
+		if err != doppelgangerreader.ErrBufferLimitExceeded {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferLimitExceeded, err)
+		}
+		if !doppelgangerreader.IsBufferLimitError(err) {
+			t.Fatalf("expected IsBufferLimitError to return true")
+		}
+
+		// buffered data is still readable
+		reader2 := factory.NewDoppelganger()
+		buf = readAtLeast(t, reader2, 5)
+		if !bytes.Equal(payload[:5], buf) {
+			t.Fatalf("expected %v, but got %v", payload[:5], buf)
+		}
+		if _, err = reader2.Read(make([]byte, 1)); err != doppelgangerreader.ErrBufferLimitExceeded {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferLimitExceeded, err)
+		}
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithBufferLimit(2),
+			doppelgangerreader.WithChunkSize(1),
+			doppelgangerreader.WithBufferEviction(),
+		)
+		defer factory.Close()
+
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	})
+
+	if doppelgangerreader.IsBufferLimitError(io.EOF) {
+		t.Fatalf("expected IsBufferLimitError to return false")
+	}
+}
+
+type closeCounter struct {
+	io.Reader
+	closes int
+	err    error
+}
+
+func (c *closeCounter) Close() error {
+	c.closes++
+	return c.err
+}
+
+func TestWithCloseSource(t *testing.T) {
+	source := &closeCounter{
+		Reader: bytes.NewBufferString("Hello World"),
+		err:    errors.New("already closed"),
+	}
+	factory := doppelgangerreader.NewFactoryWithOptions(source, doppelgangerreader.WithCloseSource())
+	if err := factory.Close(); err != source.err {
+		t.Fatalf("expected %v, but got %v", source.err, err)
+	}
+	if err := factory.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if source.closes != 1 {
+		t.Fatalf("expected 1, but got %d", source.closes)
+	}
+
+	// sources that can not be closed
+	factory = doppelgangerreader.NewFactoryWithOptions(bytes.NewBufferString("Hello World"), doppelgangerreader.WithCloseSource())
+	if err := factory.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestWithReadRate(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 300)
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithReadRate(1000))
+	defer factory.Close()
+
+	// the first 1000 bytes are a burst, so use a second doppelganger to consume more than that
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		buf, err := ioutil.ReadAll(factory.NewDoppelganger())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+	if d := time.Since(start); d > time.Millisecond*100 {
+		t.Fatalf("expected the burst to be fast, but it took %v", d)
+	}
+
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(bytes.Repeat([]byte("a"), 1200)),
+		doppelgangerreader.WithReadRate(1000),
+	)
+	defer factory.Close()
+	start = time.Now()
+	if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if d := time.Since(start); d < time.Millisecond*150 {
+		t.Fatalf("expected at least 150ms, but got %v", d)
+	}
+
+	// closing the factory interrupts the delay
+	factory = doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(bytes.Repeat([]byte("a"), 5000)),
+		doppelgangerreader.WithReadRate(1000),
+	)
+	reader := factory.NewDoppelganger()
+	// consume the burst and create a debt
+	readAtLeast(t, reader, 2000)
+	result := make(chan error)
+	go func() {
+		_, err := reader.Read(make([]byte, 1000))
+		result <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	factory.Close()
+	select {
+	case err := <-result:
+		if err != io.EOF {
+			t.Fatalf("expected %v, but got %v", io.EOF, err)
+		}
+	case <-time.After(time.Millisecond * 500):
+		t.Fatalf("expected the read to be interrupted")
+	}
+}
+
+func TestWithOnSourceEOF(t *testing.T) {
+	payload := []byte("Hello World")
+	sourceErr := errors.New("connection reset")
+	// the callbacks are called by the goroutine reading the source
+	totals := make(chan int64, 10)
+	errs := make(chan error, 10)
+	opts := []doppelgangerreader.Option{
+		doppelgangerreader.WithOnSourceEOF(func(totalBytes int64) {
+			totals <- totalBytes
+		}),
+		doppelgangerreader.WithOnSourceError(func(err error) {
+			errs <- err
+		}),
+	}
+
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), opts...)
+	for i := 0; i < 2; i++ {
+		if _, err := ioutil.ReadAll(factory.NewDoppelganger()); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+	if total := <-totals; total != 11 {
+		t.Fatalf("expected 11, but got %d", total)
+	}
+	factory.Close()
+
+	factory = doppelgangerreader.NewFactoryWithOptions(io.MultiReader(bytes.NewReader(payload), &errorReader{sourceErr}), opts...)
+	if _, err := ioutil.ReadAll(factory.NewDoppelganger()); !errors.Is(err, sourceErr) {
+		t.Fatalf("expected %v, but got %v", sourceErr, err)
+	}
+	if err := <-errs; err != sourceErr {
+		t.Fatalf("expected %v, but got %v", sourceErr, err)
+	}
+	factory.Close()
+
+	// every callback has been called only once
+	time.Sleep(time.Millisecond * 10)
+	if len(totals) != 0 || len(errs) != 0 {
+		t.Fatalf("expected no more calls, but got %d and %d", len(totals), len(errs))
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		bytes.NewReader(payload),
+		append(doppelgangerreader.DefaultOptions(), doppelgangerreader.WithBufferEviction())...,
+	)
+	defer factory.Close()
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	if buf, _ := ioutil.ReadAll(reader); !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+	// eviction has been applied on top of the defaults
+	if n := factory.BufferedBytes(); n != 0 {
+		t.Fatalf("expected %d, but got %d", 0, n)
+	}
+}
+
+func TestNewFactoryWithOptionsNested(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+
+	// without options the original factory is used
+	nested := doppelgangerreader.NewFactoryWithOptions(reader)
+	nestedReader := nested.NewDoppelganger()
+	defer nestedReader.Close()
+	if doppelgangerreader.GetFactory(nestedReader) != factory {
+		t.Fatal("expected the doppelganger to be created by the original factory")
+	}
+
+	// the default options behave like no options
+	defaults := doppelgangerreader.NewFactoryWithOptions(reader, doppelgangerreader.DefaultOptions()...)
+	defaultsReader := defaults.NewDoppelganger()
+	defer defaultsReader.Close()
+	if doppelgangerreader.GetFactory(defaultsReader) != factory {
+		t.Fatal("expected the doppelganger to be created by the original factory")
+	}
+
+	// with options a new factory is created
+	other := doppelgangerreader.NewFactoryWithOptions(reader, doppelgangerreader.WithChunkSize(4))
+	defer other.Close()
+	otherReader := other.NewDoppelganger()
+	defer otherReader.Close()
+	if doppelgangerreader.GetFactory(otherReader) == factory {
+		t.Fatal("expected the doppelganger to be created by a new factory")
+	}
+}
+
+func TestWithNoLock(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 1000)
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		iotest.HalfReader(bytes.NewReader(payload)),
+		doppelgangerreader.WithNoLock(),
+		doppelgangerreader.WithChunkSize(64),
+	)
+	defer factory.Close()
+
+	reader1 := factory.NewDoppelganger()
+	defer reader1.Close()
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+
+	// interleave the doppelgangers on a single goroutine
+	var buf1, buf2 []byte
+	p := make([]byte, 100)
+	for len(buf1) < len(payload) || len(buf2) < len(payload) {
+		n, err1 := reader1.Read(p)
+		buf1 = append(buf1, p[:n]...)
+		n, err2 := reader2.Read(p)
+		buf2 = append(buf2, p[:n]...)
+		if err1 == io.EOF && err2 == io.EOF {
+			break
+		}
+	}
+	if !bytes.Equal(payload, buf1) {
+		t.Fatalf("expected %v, but got %v", payload, buf1)
+	}
+	if !bytes.Equal(payload, buf2) {
+		t.Fatalf("expected %v, but got %v", payload, buf2)
+	}
+}
+
+func TestWithNoLockBlockingPolicies(t *testing.T) {
+	payload := []byte("Hello World")
+	t.Run("BlockOnFull", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithNoLock(),
+			doppelgangerreader.WithMaxBufferSize(4),
+			doppelgangerreader.WithBufferFullBehavior(doppelgangerreader.BlockOnFull),
+		)
+		defer factory.Close()
+		reader1 := factory.NewDoppelganger()
+		defer reader1.Close()
+		reader2 := factory.NewDoppelganger()
+		defer reader2.Close()
+
+		readAtLeast(t, reader1, 4)
+		if _, err := reader1.Read(make([]byte, 1)); err != doppelgangerreader.ErrBufferFull {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferFull, err)
+		}
+	})
+	t.Run("BlockOnExceed", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithNoLock(),
+			doppelgangerreader.WithBufferLimit(4),
+			doppelgangerreader.WithBufferLimitBehavior(doppelgangerreader.BlockOnExceed),
+		)
+		defer factory.Close()
+		reader := factory.NewDoppelganger()
+		defer reader.Close()
+
+		readAtLeast(t, reader, 4)
+		if _, err := reader.Read(make([]byte, 1)); err != doppelgangerreader.ErrBufferLimitExceeded {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrBufferLimitExceeded, err)
+		}
+	})
+	t.Run("BlockOnMaxDoppelgangers", func(t *testing.T) {
+		factory := doppelgangerreader.NewFactoryWithOptions(
+			bytes.NewReader(payload),
+			doppelgangerreader.WithNoLock(),
+			doppelgangerreader.WithMaxDoppelgangers(1),
+			doppelgangerreader.WithMaxDoppelgangersPolicy(doppelgangerreader.BlockOnMaxDoppelgangers),
+		)
+		defer factory.Close()
+		reader := factory.NewDoppelganger()
+		defer reader.Close()
+
+		if _, err := factory.NewDoppelgangerErr(); err != doppelgangerreader.ErrTooManyDoppelgangers {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrTooManyDoppelgangers, err)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// NewPacedDoppelganger creates a new reader that acts like the original reader
+// but does not deliver more than targetBytesPerSecond on average.
+// Short bursts (up to one second worth of data, but at least one byte) are allowed, the reader will pause
+// afterwards to pay back the debt. Closing the reader (or the factory) interrupts the pause.
+// (0 or less disables the pacing)
+func NewPacedDoppelganger(factory DoppelgangerFactory, targetBytesPerSecond float64) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	if targetBytesPerSecond <= 0 {
+		return reader
+	}
+	r := &pacedReader{
+		Reader: reader,
+		Closer: reader,
+		rate:   targetBytesPerSecond,
+		bucket: targetBytesPerSecond,
+		last:   time.Now(),
+		done:   make(chan struct{}),
+	}
+	// rates below 1 B/s still have to deliver whole bytes
+	if r.bucket < 1 {
+		r.bucket = 1
+	}
+	r.tokens = r.bucket
+	if instance, ok := reader.(*readerInstance); ok {
+		instance.DoppelBase.mu.Lock()
+		r.ended = instance.ended()
+		instance.DoppelBase.mu.Unlock()
+	}
+	return r
+}
+
+// pacedReader uses a token bucket to shape the delivery rate
+type pacedReader struct {
+	io.Reader
+	io.Closer
+	rate float64
+	// bucket is the number of bytes that can be delivered in a burst
+	bucket float64
+	// tokens is the number of bytes that can be delivered right now, a negative value is a debt
+	tokens float64
+	last   time.Time
+	// done is closed by Close, ended when the doppelganger ends (e.g. the factory has been closed)
+	done      chan struct{}
+	ended     <-chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *pacedReader) refill() {
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.bucket {
+		r.tokens = r.bucket
+	}
+	r.last = now
+}
+
+// wait pays back the debt, it returns false if the reader has been closed in the meantime.
+func (r *pacedReader) wait() bool {
+	timer := time.NewTimer(time.Duration(-r.tokens / r.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.done:
+		return false
+	case <-r.ended:
+		return false
+	}
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	r.refill()
+	if r.tokens < 0 {
+		if !r.wait() {
+			return 0, ErrDoppelgangerClosed
+		}
+		r.refill()
+	}
+	// do not burst more than the bucket can hold
+	if max := int(r.bucket); len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.Reader.Read(p)
+	r.tokens -= float64(n)
+	return n, err
+}
+
+func (r *pacedReader) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+	return r.Closer.Close()
+}
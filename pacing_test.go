@@ -0,0 +1,90 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewPacedDoppelganger(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 20)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	// the first 200 bytes are a burst, the remaining 20 bytes should take about 100ms
+	reader := doppelgangerreader.NewPacedDoppelganger(factory, 200)
+	defer reader.Close()
+
+	start := time.Now()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+	if d := time.Since(start); d < time.Millisecond*90 {
+		t.Fatalf("expected at least %v, but got %v", time.Millisecond*90, d)
+	}
+}
+
+func TestNewPacedDoppelgangerClose(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	// the bucket holds one byte, after two reads the reader has to pay back a debt of about 10s
+	reader := doppelgangerreader.NewPacedDoppelganger(factory, 0.1)
+	for i := 0; i < 2; i++ {
+		if buf := read(t, reader, 100); len(buf) != 1 {
+			t.Fatalf("expected %d, but got %d", 1, len(buf))
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := reader.Read(make([]byte, 100))
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	if err := reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	select {
+	case err := <-errs:
+		if err != doppelgangerreader.ErrDoppelgangerClosed {
+			t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrDoppelgangerClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pause to be interrupted")
+	}
+}
+
+func TestNewPacedDoppelgangerFactoryClose(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+
+	reader := doppelgangerreader.NewPacedDoppelganger(factory, 0.1)
+	defer reader.Close()
+	read(t, reader, 100)
+	read(t, reader, 100)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := reader.Read(make([]byte, 100))
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	if err := factory.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatalf("expected error, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pause to be interrupted")
+	}
+}
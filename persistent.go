@@ -0,0 +1,69 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"strconv"
+)
+
+// KVStore is a key value store that is used by NewPersistentDoppelganger to persist the consumed data
+type KVStore interface {
+	// Get returns the value of key, if the key does not exist it must return a nil value and no error
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+}
+
+// NewPersistentDoppelganger creates a new reader that acts like the original reader
+// and persists every consumed chunk in store under the key "id:offset",
+// the position after the last persisted chunk is stored under the key "id".
+// If a persistent doppelganger with the same id has been created before (on any factory),
+// the reader resumes at that position. Errors of store are reported by Read.
+func NewPersistentDoppelganger(factory DoppelgangerFactory, store KVStore, id string) (io.ReadCloser, error) {
+	value, err := store.Get([]byte(id))
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if value != nil {
+		if offset, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	reader, err := factory.NewDoppelgangerAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	return &persistentReader{
+		Reader: reader,
+		Closer: reader,
+		store:  store,
+		id:     id,
+		offset: offset,
+	}, nil
+}
+
+type persistentReader struct {
+	io.Reader
+	io.Closer
+	store  KVStore
+	id     string
+	offset int64
+}
+
+func (r *persistentReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if perr := r.persist(p[:n]); perr != nil {
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+func (r *persistentReader) persist(p []byte) error {
+	key := r.id + ":" + strconv.FormatInt(r.offset, 10)
+	if err := r.store.Put([]byte(key), p); err != nil {
+		return err
+	}
+	r.offset += int64(len(p))
+	return r.store.Put([]byte(r.id), []byte(strconv.FormatInt(r.offset, 10)))
+}
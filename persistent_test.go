@@ -0,0 +1,80 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+type mapStore map[string][]byte
+
+func (s mapStore) Get(key []byte) ([]byte, error) {
+	return s[string(key)], nil
+}
+
+func (s mapStore) Put(key, value []byte) error {
+	s[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+type failStore struct {
+	mapStore
+	err error
+}
+
+func (s failStore) Put(key, value []byte) error {
+	return s.err
+}
+
+func TestNewPersistentDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	store := mapStore{}
+
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	reader, err := doppelgangerreader.NewPersistentDoppelganger(factory, store, "stream")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf := readAtLeast(t, reader, 6)
+	if !bytes.Equal(payload[:6], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:6], buf)
+	}
+	if v := store["stream:0"]; !bytes.Equal(payload[:6], v) {
+		t.Fatalf("expected %v, but got %v", payload[:6], v)
+	}
+	factory.Close()
+
+	// a new factory for the same stream resumes at the persisted position
+	factory = doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+	reader, err = doppelgangerreader.NewPersistentDoppelganger(factory, store, "stream")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf, err = ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+	if v := store["stream:6"]; !bytes.Equal(payload[6:], v) {
+		t.Fatalf("expected %v, but got %v", payload[6:], v)
+	}
+	if v := string(store["stream"]); v != "11" {
+		t.Fatalf("expected 11, but got %s", v)
+	}
+
+	// errors of the store are reported by Read
+	failing := failStore{mapStore: mapStore{}, err: errors.New("store is down")}
+	reader, err = doppelgangerreader.NewPersistentDoppelganger(factory, failing, "stream")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err = reader.Read(make([]byte, 4)); err != failing.err {
+		t.Fatalf("expected %v, but got %v", failing.err, err)
+	}
+}
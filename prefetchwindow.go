@@ -0,0 +1,85 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"sync"
+)
+
+// NewPrefetchingDoppelganger creates a new reader that acts like the original reader,
+// after every Read the factory reads the next n bytes from the source in the background.
+// Other doppelgangers benefit from the prefetched data as well.
+// The background prefetching stops when the reader is closed or reaches the end of the stream
+// and when the factory is closed or reset.
+func NewPrefetchingDoppelganger(factory DoppelgangerFactory, n int64) io.ReadCloser {
+	reader := factory.NewDoppelganger().(*readerInstance)
+	r := &prefetchingReader{
+		ReadCloser: reader,
+		window:     n,
+		targets:    make(chan int64, 1),
+		done:       make(chan struct{}),
+	}
+	reader.DoppelBase.mu.Lock()
+	ended := reader.ended()
+	reader.DoppelBase.mu.Unlock()
+	go r.prefetch(reader, ended)
+	return r
+}
+
+type prefetchingReader struct {
+	io.ReadCloser
+	window int64
+	pos    int64
+	// targets receives the positions the buffer should be filled up to
+	targets   chan int64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *prefetchingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.pos += int64(n)
+	if err != nil {
+		// the stream ended (or failed), there is nothing left to prefetch
+		r.stop()
+	} else if r.window > 0 {
+		select {
+		case r.targets <- r.pos + r.window:
+		default:
+			// there is already a prefetch pending, the next read will trigger another one
+		}
+	}
+	return n, err
+}
+
+// prefetch fills the buffer up to the targets until the reader stops or ended is closed.
+func (r *prefetchingReader) prefetch(reader *readerInstance, ended <-chan struct{}) {
+	factory := reader.DoppelBase
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ended:
+			return
+		case target := <-r.targets:
+			factory.mu.Lock()
+			err := factory.fillBuffer(target, 0, ended)
+			factory.mu.Unlock()
+			if err != nil {
+				// the source ended, the reader has been closed or the factory has been closed or reset
+				return
+			}
+		}
+	}
+}
+
+// stop stops the background prefetching.
+func (r *prefetchingReader) stop() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+func (r *prefetchingReader) Close() error {
+	r.stop()
+	return r.ReadCloser.Close()
+}
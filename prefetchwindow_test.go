@@ -0,0 +1,102 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewPrefetchingDoppelganger(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 100)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader := doppelgangerreader.NewPrefetchingDoppelganger(factory, 100)
+	defer reader.Close()
+
+	buf := readAtLeast(t, reader, 10)
+	if !bytes.Equal(payload[:10], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:10], buf)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for factory.BufferedBytes() < 110 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least 110 buffered bytes, but got %d", factory.BufferedBytes())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForGoroutines waits until the number of goroutines dropped to n
+func waitForGoroutines(t *testing.T, n int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at most %d goroutines, but got %d", n, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewPrefetchingDoppelgangerStops(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 100)
+
+	t.Run("eof", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+			buf, err := ioutil.ReadAll(doppelgangerreader.NewPrefetchingDoppelganger(factory, 100))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !bytes.Equal(payload, buf) {
+				t.Fatalf("expected %v, but got %v", payload, buf)
+			}
+			factory.Close()
+		}
+		waitForGoroutines(t, goroutines)
+	})
+
+	t.Run("close all doppelgangers", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+		reader := doppelgangerreader.NewPrefetchingDoppelganger(factory, 100)
+		readAtLeast(t, reader, 10)
+		if err := factory.CloseAllDoppelgangers(); err != nil {
+			t.Fatal(err)
+		}
+		waitForGoroutines(t, goroutines)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+		factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+		defer factory.Close()
+		reader := doppelgangerreader.NewPrefetchingDoppelganger(factory, 100)
+		readAtLeast(t, reader, 10)
+		if err := factory.Reset(bytes.NewReader(payload)); err != nil {
+			t.Fatal(err)
+		}
+		waitForGoroutines(t, goroutines)
+	})
+
+	t.Run("blocked by a stalled doppelganger", func(t *testing.T) {
+		goroutines := runtime.NumGoroutine()
+		factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithMaxBufferSize(50))
+		stalled := factory.NewDoppelganger()
+		defer stalled.Close()
+		reader := doppelgangerreader.NewPrefetchingDoppelganger(factory, 100)
+		defer reader.Close()
+		readAtLeast(t, reader, 10)
+		// give the prefetch some time to block on the full buffer
+		time.Sleep(time.Millisecond * 50)
+		factory.Close()
+		waitForGoroutines(t, goroutines)
+	})
+}
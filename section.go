@@ -0,0 +1,83 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"io"
+)
+
+// NewDoppelgangerSection creates a new reader that delivers the bytes [off, off+n) of the stream,
+// like io.SectionReader. The data will be read from the source as needed, if the source ends before off+n
+// the section ends there. io.EOF is returned if the source ends before off.
+// The returned reader implements io.Seeker, the positions are relative to off and restricted to the section.
+func NewDoppelgangerSection(factory DoppelgangerFactory, off, n int64) (io.ReadCloser, error) {
+	if n < 0 {
+		return nil, errors.New("negative size")
+	}
+	reader, err := factory.NewDoppelgangerAt(off)
+	if err != nil {
+		return nil, err
+	}
+	return &sectionReader{
+		reader: reader.(io.ReadSeekCloser),
+		off:    off,
+		n:      n,
+	}, nil
+}
+
+type sectionReader struct {
+	reader io.ReadSeekCloser
+	off    int64
+	n      int64
+	// pos is the position relative to off, it can be beyond n after seeking
+	pos int64
+}
+
+func (r *sectionReader) Read(p []byte) (int, error) {
+	if r.pos >= r.n {
+		return 0, io.EOF
+	}
+	if remaining := r.n - r.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+// Seek sets the position for the next Read relative to the start of the section,
+// io.SeekEnd is relative to the end of the section (off+n).
+func (r *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.n + offset
+	default:
+		return r.pos, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return r.pos, errors.New("negative position")
+	}
+	// reading beyond the section returns io.EOF, the doppelganger does not need to move further
+	target := pos
+	if target > r.n {
+		target = r.n
+	}
+	_, err := r.reader.Seek(r.off+target, io.SeekStart)
+	if err == ErrSeekBeyondEnd {
+		// the stream ends inside the section, like io.SectionReader the next Read returns io.EOF
+		_, err = r.reader.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		return r.pos, err
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *sectionReader) Close() error {
+	return r.reader.Close()
+}
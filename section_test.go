@@ -0,0 +1,98 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewDoppelgangerSection(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	reader, err := doppelgangerreader.NewDoppelgangerSection(factory, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload[2:7], buf) {
+		t.Fatalf("expected %v, but got %v", payload[2:7], buf)
+	}
+
+	// seeking is restricted to the section
+	seeker := reader.(io.Seeker)
+	if pos, err := seeker.Seek(-2, io.SeekEnd); err != nil || pos != 3 {
+		t.Fatalf("expected %d, but got %d (%v)", 3, pos, err)
+	}
+	if buf, _ = ioutil.ReadAll(reader); !bytes.Equal(payload[5:7], buf) {
+		t.Fatalf("expected %v, but got %v", payload[5:7], buf)
+	}
+	if pos, err := seeker.Seek(0, io.SeekStart); err != nil || pos != 0 {
+		t.Fatalf("expected %d, but got %d (%v)", 0, pos, err)
+	}
+	if buf, _ = ioutil.ReadAll(reader); !bytes.Equal(payload[2:7], buf) {
+		t.Fatalf("expected %v, but got %v", payload[2:7], buf)
+	}
+	if _, err = seeker.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("expected an error")
+	}
+	if pos, err := seeker.Seek(10, io.SeekStart); err != nil || pos != 10 {
+		t.Fatalf("expected %d, but got %d (%v)", 10, pos, err)
+	}
+	if _, err = reader.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+}
+
+func TestNewDoppelgangerSectionBeyondEnd(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	// the section ends at the end of the source
+	reader, err := doppelgangerreader.NewDoppelgangerSection(factory, 6, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if buf, _ := ioutil.ReadAll(reader); !bytes.Equal(payload[6:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[6:], buf)
+	}
+
+	// seeking beyond the end of the stream but inside the section
+	seeker := reader.(io.Seeker)
+	for _, whence := range []int{io.SeekEnd, io.SeekStart} {
+		pos, err := seeker.Seek(50, whence)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if _, err = reader.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("expected %v at %d, but got %v", io.EOF, pos, err)
+		}
+	}
+	if pos, err := seeker.Seek(0, io.SeekEnd); err != nil || pos != 100 {
+		t.Fatalf("expected %d, but got %d (%v)", 100, pos, err)
+	}
+	if _, err = seeker.Seek(1, io.SeekStart); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if buf, _ := ioutil.ReadAll(reader); !bytes.Equal(payload[7:], buf) {
+		t.Fatalf("expected %v, but got %v", payload[7:], buf)
+	}
+
+	if _, err = doppelgangerreader.NewDoppelgangerSection(factory, 100, 1); err != io.EOF {
+		t.Fatalf("expected %v, but got %v", io.EOF, err)
+	}
+	if _, err = doppelgangerreader.NewDoppelgangerSection(factory, 0, -1); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,57 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"io"
+)
+
+// NewSentinelDoppelganger creates a new reader that acts like the original reader
+// and calls fn the first time sentinel appears in the consumed data.
+// The sentinel will be delivered like any other data, after fn was called the scanning stops.
+func NewSentinelDoppelganger(factory DoppelgangerFactory, sentinel []byte, fn func()) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	return &sentinelReader{
+		Reader:   reader,
+		Closer:   reader,
+		sentinel: append([]byte(nil), sentinel...),
+		fn:       fn,
+	}
+}
+
+type sentinelReader struct {
+	io.Reader
+	io.Closer
+	sentinel []byte
+	fn       func()
+	// tail holds the last bytes that have been consumed,
+	// so we can find sentinels that span over multiple reads
+	tail  []byte
+	found bool
+}
+
+func (r *sentinelReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && !r.found {
+		r.scan(p[:n])
+	}
+	return n, err
+}
+
+func (r *sentinelReader) scan(p []byte) {
+	if len(r.sentinel) == 0 {
+		r.found = true
+		r.fn()
+		return
+	}
+	data := append(r.tail, p...)
+	if bytes.Contains(data, r.sentinel) {
+		r.found = true
+		r.tail = nil
+		r.fn()
+		return
+	}
+	if keep := len(r.sentinel) - 1; len(data) > keep {
+		data = data[len(data)-keep:]
+	}
+	r.tail = append(r.tail[:0], data...)
+}
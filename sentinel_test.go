@@ -0,0 +1,49 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewSentinelDoppelganger(t *testing.T) {
+	payload := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\nHello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	calls := 0
+	var pos int
+	reader := doppelgangerreader.NewSentinelDoppelganger(factory, []byte("\r\n\r\n"), func() {
+		calls++
+	})
+	defer reader.Close()
+
+	// read byte by byte, so the sentinel spans over multiple reads
+	var buf bytes.Buffer
+	var b [1]byte
+	for {
+		n, err := reader.Read(b[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		buf.Write(b[:n])
+		if calls == 1 && pos == 0 {
+			pos = buf.Len()
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected 1, but got %d", calls)
+	}
+	if expected := bytes.Index(payload, []byte("\r\n\r\n")) + 4; pos != expected {
+		t.Fatalf("expected %d, but got %d", expected, pos)
+	}
+	if !bytes.Equal(payload, buf.Bytes()) {
+		t.Fatalf("expected %v, but got %v", payload, buf.Bytes())
+	}
+}
@@ -0,0 +1,42 @@
+package doppelgangerreader
+
+import (
+	"sync/atomic"
+)
+
+// FactoryStats is a snapshot of the counters of a factory, see DoppelgangerFactory.Stats
+type FactoryStats struct {
+	// SourceBytesRead is the number of bytes read from the original reader (excluding the prefix of NewFactoryWithData)
+	SourceBytesRead int64
+	// ActiveDoppelgangers is the number of doppelgangers that have not been closed
+	ActiveDoppelgangers int
+	// ClosedDoppelgangers is the number of doppelgangers that have been closed or removed
+	ClosedDoppelgangers int
+}
+
+// Stats returns a snapshot of the counters of the factory
+func (factory *doppelgangerFactory) Stats() FactoryStats {
+	factory.mu.RLock()
+	defer factory.mu.RUnlock()
+	return FactoryStats{
+		SourceBytesRead:     factory.size() - factory.prefixSize,
+		ActiveDoppelgangers: len(factory.readers),
+		ClosedDoppelgangers: factory.closedReaders,
+	}
+}
+
+// DoppelgangerStats is a snapshot of the counters of a doppelganger, see Stats of the doppelgangers
+type DoppelgangerStats struct {
+	// BytesDelivered is the number of bytes the doppelganger returned (skipped bytes are not included)
+	BytesDelivered int64
+	// ReadCalls is the number of read operations (Read, ReadRune, ReadBytes and WriteTo)
+	ReadCalls int64
+}
+
+// Stats returns a snapshot of the counters of the reader, it can be called while the reader is in use
+func (r *readerInstance) Stats() DoppelgangerStats {
+	return DoppelgangerStats{
+		BytesDelivered: atomic.LoadInt64(&r.bytesDelivered),
+		ReadCalls:      atomic.LoadInt64(&r.readCalls),
+	}
+}
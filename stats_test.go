@@ -0,0 +1,72 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestStats(t *testing.T) {
+	factory := doppelgangerreader.NewFactoryWithData([]byte(">"), bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	type statser interface {
+		Stats() doppelgangerreader.DoppelgangerStats
+	}
+
+	reader1 := factory.NewDoppelganger()
+	reader2 := factory.NewDoppelganger()
+	defer reader2.Close()
+
+	read(t, reader1, 6)
+	if _, err := reader1.(io.Seeker).Seek(3, io.SeekCurrent); err != nil {
+		t.Fatal(err)
+	}
+	read(t, reader1, 100)
+	if err := reader1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := doppelgangerreader.DoppelgangerStats{BytesDelivered: 9, ReadCalls: 2}
+	if stats := reader1.(statser).Stats(); stats != expected {
+		t.Fatalf("expected %+v, but got %+v", expected, stats)
+	}
+
+	if _, err := ioutil.ReadAll(reader2); err != nil {
+		t.Fatal(err)
+	}
+	if stats := reader2.(statser).Stats(); stats.BytesDelivered != 12 {
+		t.Fatalf("expected %d, but got %d", 12, stats.BytesDelivered)
+	}
+
+	expectedFactory := doppelgangerreader.FactoryStats{
+		SourceBytesRead:     11,
+		ActiveDoppelgangers: 1,
+		ClosedDoppelgangers: 1,
+	}
+	if stats := factory.Stats(); stats != expectedFactory {
+		t.Fatalf("expected %+v, but got %+v", expectedFactory, stats)
+	}
+}
+
+func TestStatsDuplicateName(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	defer factory.Close()
+
+	reader, err := factory.NewNamedDoppelganger("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err = factory.NewNamedDoppelganger("name"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the rejected doppelganger has never been created
+	expected := doppelgangerreader.FactoryStats{ActiveDoppelgangers: 1}
+	if stats := factory.Stats(); stats != expected {
+		t.Fatalf("expected %+v, but got %+v", expected, stats)
+	}
+}
@@ -0,0 +1,132 @@
+package doppelgangerreader
+
+import (
+	"errors"
+	"sync"
+)
+
+// Subscribe registers ch to receive a copy of every chunk that is read from the source,
+// the channel is closed once the source ended (io.EOF or an error), the factory has been closed or reset.
+// If ch is full the push blocks the source reads or drops the chunk, see WithSubscribeDropPolicy.
+// If the source has already been drained ch receives the buffered data (which is never dropped)
+// and is closed afterwards.
+// cancel deregisters ch without closing it, no chunk will be sent after cancel returned.
+// It returns ErrFactoryClosed if the factory has been closed.
+func (factory *doppelgangerFactory) Subscribe(ch chan<- []byte) (cancel func(), err error) {
+	if ch == nil {
+		return nil, errors.New("nil channel")
+	}
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	if factory.closed {
+		return nil, ErrFactoryClosed
+	}
+	sub := &subscriber{
+		ch:   ch,
+		done: make(chan struct{}),
+	}
+	cancel = func() {
+		factory.unsubscribe(sub)
+	}
+	if factory.err == nil {
+		sub.drop = factory.config.subscribeDropPolicy == DropOnFullSubscriber
+		factory.subscribers = append(factory.subscribers, sub)
+		return cancel, nil
+	}
+
+	// the source has been drained, copy the buffered data because it might be evicted while we send it
+	var chunks [][]byte
+	for pos := factory.start(); pos < factory.size(); {
+		p := factory.buffer.slice(pos)
+		chunks = append(chunks, append([]byte(nil), p...))
+		pos += int64(len(p))
+	}
+	go func() {
+		for _, chunk := range chunks {
+			sub.send(chunk, nil)
+		}
+		sub.end(true)
+	}()
+	return cancel, nil
+}
+
+// unsubscribe removes sub from the subscribers and ends it without closing its channel.
+func (factory *doppelgangerFactory) unsubscribe(sub *subscriber) {
+	factory.mu.Lock()
+	for i, s := range factory.subscribers {
+		if s == sub {
+			factory.subscribers = append(factory.subscribers[:i], factory.subscribers[i+1:]...)
+			break
+		}
+	}
+	factory.mu.Unlock()
+	sub.end(false)
+}
+
+// publish sends a copy of p to every subscriber, the lock is released while sending.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) publish(p []byte, closedCh <-chan struct{}) {
+	subscribers := append([]*subscriber(nil), factory.subscribers...)
+	factory.mu.Unlock()
+	for _, sub := range subscribers {
+		sub.send(append([]byte(nil), p...), closedCh)
+	}
+	factory.mu.Lock()
+}
+
+// endSubscriptions closes the channels of all subscribers.
+// factory.mu must be held.
+func (factory *doppelgangerFactory) endSubscriptions() {
+	for _, sub := range factory.subscribers {
+		sub.end(true)
+	}
+	factory.subscribers = nil
+}
+
+type subscriber struct {
+	ch   chan<- []byte
+	drop bool
+	// done is closed when the subscription ends, it stops a blocking send
+	done     chan struct{}
+	doneOnce sync.Once
+	// mu is held while sending to ch
+	mu    sync.Mutex
+	ended bool
+}
+
+// send sends chunk to the channel of the subscriber, it gives up if the subscription or the factory (closedCh) ends.
+func (sub *subscriber) send(chunk []byte, closedCh <-chan struct{}) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.ended {
+		return
+	}
+	if sub.drop {
+		select {
+		case sub.ch <- chunk:
+		default:
+		}
+		return
+	}
+	select {
+	case sub.ch <- chunk:
+	case <-sub.done:
+	case <-closedCh:
+	}
+}
+
+// end ends the subscription and waits for a pending send, if closeCh is set the channel will be closed.
+func (sub *subscriber) end(closeCh bool) {
+	sub.doneOnce.Do(func() {
+		close(sub.done)
+	})
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.ended {
+		return
+	}
+	sub.ended = true
+	if closeCh {
+		close(sub.ch)
+	}
+}
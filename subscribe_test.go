@@ -0,0 +1,149 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func collect(ch <-chan []byte) <-chan []byte {
+	result := make(chan []byte, 1)
+	go func() {
+		var buf []byte
+		for chunk := range ch {
+			buf = append(buf, chunk...)
+		}
+		result <- buf
+	}()
+	return result
+}
+
+func TestSubscribe(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	ch := make(chan []byte)
+	cancel, err := factory.Subscribe(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	result := collect(ch)
+
+	reader := factory.NewDoppelganger()
+	defer reader.Close()
+	if buf, _ := ioutil.ReadAll(reader); !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	// the channel is closed after the end of the source
+	if buf := <-result; !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestSubscribeAfterDrain(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(bytes.NewReader(payload), doppelgangerreader.WithChunkSize(4))
+	defer factory.Close()
+
+	if err := factory.Drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan []byte)
+	cancel, err := factory.Subscribe(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	var chunks [][]byte
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected %d, but got %d", 3, len(chunks))
+	}
+	if buf := bytes.Join(chunks, nil); !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+}
+
+func TestSubscribeDropPolicy(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactoryWithOptions(
+		iotest.OneByteReader(bytes.NewReader(payload)),
+		doppelgangerreader.WithSubscribeDropPolicy(doppelgangerreader.DropOnFullSubscriber),
+	)
+	defer factory.Close()
+
+	ch := make(chan []byte, 1)
+	cancel, err := factory.Subscribe(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// nobody receives, so everything but the first chunk is dropped
+	if err := factory.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if buf := <-collect(ch); !bytes.Equal(payload[:1], buf) {
+		t.Fatalf("expected %v, but got %v", payload[:1], buf)
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(iotest.OneByteReader(bytes.NewReader(payload)))
+	defer factory.Close()
+
+	ch := make(chan []byte)
+	cancel, err := factory.Subscribe(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- factory.Drain()
+	}()
+	if chunk := <-ch; !bytes.Equal(payload[:1], chunk) {
+		t.Fatalf("expected %v, but got %v", payload[:1], chunk)
+	}
+	// the source reads are blocked by the subscriber until it cancels
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case chunk, ok := <-ch:
+		t.Fatalf("expected nothing, but got %v (%v)", chunk, ok)
+	default:
+	}
+}
+
+func TestSubscribeClosedFactory(t *testing.T) {
+	factory := doppelgangerreader.NewFactory(bytes.NewBufferString("Hello World"))
+	ch := make(chan []byte, 1)
+	cancel, err := factory.Subscribe(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	if err := factory.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("expected a closed channel")
+	}
+	if _, err := factory.Subscribe(make(chan []byte)); err != doppelgangerreader.ErrFactoryClosed {
+		t.Fatalf("expected %v, but got %v", doppelgangerreader.ErrFactoryClosed, err)
+	}
+}
@@ -0,0 +1,42 @@
+package doppelgangerreader
+
+import (
+	"io"
+)
+
+// NewTransformedDoppelganger creates a new reader that reads the original stream through the transforms,
+// the first transform wraps the doppelganger and the last one is the outermost reader.
+// Close closes every layer that implements io.Closer in reverse order (outermost first) and the doppelganger,
+// the first error that occurred is returned.
+func NewTransformedDoppelganger(factory DoppelgangerFactory, transforms ...func(io.Reader) io.Reader) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	layers := make([]io.Reader, 0, len(transforms)+1)
+	layers = append(layers, reader)
+	for _, transform := range transforms {
+		layers = append(layers, transform(layers[len(layers)-1]))
+	}
+	return &transformedReader{
+		Reader: layers[len(layers)-1],
+		layers: layers,
+	}
+}
+
+type transformedReader struct {
+	io.Reader
+	// layers holds the doppelganger followed by the results of the transforms
+	layers []io.Reader
+}
+
+func (r *transformedReader) Close() error {
+	var firstErr error
+	for i := len(r.layers) - 1; i >= 0; i-- {
+		closer, ok := r.layers[i].(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
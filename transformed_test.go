@@ -0,0 +1,65 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+type upperReader struct {
+	io.Reader
+	name   string
+	closed *[]string
+}
+
+func (r upperReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	copy(p, bytes.ToUpper(p[:n]))
+	return n, err
+}
+
+func (r upperReader) Close() error {
+	*r.closed = append(*r.closed, r.name)
+	return nil
+}
+
+func TestNewTransformedDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	var closed []string
+	reader := doppelgangerreader.NewTransformedDoppelganger(
+		factory,
+		func(r io.Reader) io.Reader {
+			return upperReader{Reader: r, name: "upper", closed: &closed}
+		},
+		func(r io.Reader) io.Reader {
+			return io.LimitReader(r, 5)
+		},
+		func(r io.Reader) io.Reader {
+			return upperReader{Reader: r, name: "outer", closed: &closed}
+		},
+	)
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if expected := []byte("HELLO"); !bytes.Equal(expected, buf) {
+		t.Fatalf("expected %v, but got %v", expected, buf)
+	}
+
+	if err = reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if expected := []string{"outer", "upper"}; !reflect.DeepEqual(expected, closed) {
+		t.Fatalf("expected %v, but got %v", expected, closed)
+	}
+	if n := factory.ActiveDoppelgangerCount(); n != 0 {
+		t.Fatalf("expected 0, but got %d", n)
+	}
+}
@@ -0,0 +1,34 @@
+package doppelgangerreader
+
+import (
+	"io"
+	"sync"
+)
+
+// NewWaitGroupDoppelganger creates a new reader that acts like the original reader,
+// it adds itself to wg and calls wg.Done() as soon as Read returns io.EOF.
+// Closing the reader does not call wg.Done().
+func NewWaitGroupDoppelganger(factory DoppelgangerFactory, wg *sync.WaitGroup) io.ReadCloser {
+	reader := factory.NewDoppelganger()
+	wg.Add(1)
+	return &waitGroupReader{
+		Reader: reader,
+		Closer: reader,
+		wg:     wg,
+	}
+}
+
+type waitGroupReader struct {
+	io.Reader
+	io.Closer
+	wg   *sync.WaitGroup
+	once sync.Once
+}
+
+func (r *waitGroupReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.once.Do(r.wg.Done)
+	}
+	return n, err
+}
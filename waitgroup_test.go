@@ -0,0 +1,35 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewWaitGroupDoppelganger(t *testing.T) {
+	payload := []byte("Hello World")
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	var wg sync.WaitGroup
+	results := make(chan []byte, 3)
+	for i := 0; i < cap(results); i++ {
+		reader := doppelgangerreader.NewWaitGroupDoppelganger(factory, &wg)
+		go func() {
+			buf, _ := ioutil.ReadAll(reader)
+			// reading again must not call Done twice
+			_, _ = reader.Read(make([]byte, 1))
+			results <- buf
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < cap(results); i++ {
+		if buf := <-results; !bytes.Equal(payload, buf) {
+			t.Fatalf("expected %v, but got %v", payload, buf)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package doppelgangerreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+// NewZlibDoppelganger creates a new reader that delivers the original stream zlib compressed with level,
+// every doppelganger has its own compression state. Close finalizes the zlib stream before
+// the doppelganger is removed from the factory.
+func NewZlibDoppelganger(factory DoppelgangerFactory, level int) (io.ReadCloser, error) {
+	r := &zlibReader{}
+	writer, err := zlib.NewWriterLevel(&r.compressed, level)
+	if err != nil {
+		return nil, err
+	}
+	r.writer = writer
+	if r.reader, err = newDoppelgangerErr(factory); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type zlibReader struct {
+	reader io.ReadCloser
+	writer *zlib.Writer
+	// compressed holds the compressed data that has not been delivered yet
+	compressed bytes.Buffer
+	err        error
+	finalized  bool
+}
+
+func (r *zlibReader) Read(p []byte) (int, error) {
+	for r.compressed.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill(len(p))
+	}
+	return r.compressed.Read(p)
+}
+
+// fill compresses the next chunk of the doppelganger
+func (r *zlibReader) fill(size int) {
+	if size < 512 {
+		size = 512
+	}
+	buf := make([]byte, size)
+	n, err := r.reader.Read(buf)
+	if n > 0 {
+		if _, werr := r.writer.Write(buf[:n]); werr != nil {
+			r.err = werr
+			return
+		}
+	}
+	if err == io.EOF {
+		r.finalized = true
+		if cerr := r.writer.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	r.err = err
+}
+
+func (r *zlibReader) Close() error {
+	var err error
+	if !r.finalized {
+		r.finalized = true
+		err = r.writer.Close()
+	}
+	if cerr := r.reader.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
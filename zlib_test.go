@@ -0,0 +1,47 @@
+package doppelgangerreader_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Eun/go-doppelgangerreader"
+)
+
+func TestNewZlibDoppelganger(t *testing.T) {
+	payload := bytes.Repeat([]byte("Hello World"), 1000)
+	factory := doppelgangerreader.NewFactory(bytes.NewReader(payload))
+	defer factory.Close()
+
+	reader, err := doppelgangerreader.NewZlibDoppelganger(factory, zlib.BestCompression)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	compressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err = reader.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(compressed) >= len(payload) {
+		t.Fatalf("expected less than %d bytes, but got %d", len(payload), len(compressed))
+	}
+
+	decompressor, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	buf, err := ioutil.ReadAll(decompressor)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(payload, buf) {
+		t.Fatalf("expected %v, but got %v", payload, buf)
+	}
+
+	if _, err = doppelgangerreader.NewZlibDoppelganger(factory, 42); err == nil {
+		t.Fatalf("expected an error")
+	}
+}